@@ -1,50 +1,92 @@
 package gox
 
 import (
+	"bytes"
 	"database/sql"
 	"database/sql/driver"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"reflect"
+	"strconv"
 	"sync"
+
+	"github.com/gopub/log"
 )
 
 var mu sync.RWMutex
-var nameToPrototype = map[string]reflect.Type{
-	"int":     reflect.TypeOf(int(1)),
-	"int8":    reflect.TypeOf(int8(1)),
-	"int16":   reflect.TypeOf(int16(1)),
-	"int32":   reflect.TypeOf(int32(1)),
-	"int64":   reflect.TypeOf(int64(1)),
-	"uint":    reflect.TypeOf(int(1)),
-	"uint8":   reflect.TypeOf(uint8(1)),
-	"uint16":  reflect.TypeOf(uint16(1)),
-	"uint32":  reflect.TypeOf(uint32(1)),
-	"uint64":  reflect.TypeOf(uint64(1)),
-	"float32": reflect.TypeOf(float32(1)),
-	"float64": reflect.TypeOf(float64(1)),
-	"bool":    reflect.TypeOf(true),
-	"string":  reflect.TypeOf(""),
+var nameToPrototype = map[string]*anyEntry{
+	"int":     {typ: reflect.TypeOf(int(1))},
+	"int8":    {typ: reflect.TypeOf(int8(1))},
+	"int16":   {typ: reflect.TypeOf(int16(1))},
+	"int32":   {typ: reflect.TypeOf(int32(1))},
+	"int64":   {typ: reflect.TypeOf(int64(1))},
+	"uint":    {typ: reflect.TypeOf(int(1))},
+	"uint8":   {typ: reflect.TypeOf(uint8(1))},
+	"uint16":  {typ: reflect.TypeOf(uint16(1))},
+	"uint32":  {typ: reflect.TypeOf(uint32(1))},
+	"uint64":  {typ: reflect.TypeOf(uint64(1))},
+	"float32": {typ: reflect.TypeOf(float32(1))},
+	"float64": {typ: reflect.TypeOf(float64(1))},
+	"bool":    {typ: reflect.TypeOf(true)},
+	"string":  {typ: reflect.TypeOf("")},
+}
+
+type anyEntry struct {
+	typ       reflect.Type
+	version   int
+	upgraders []AnyUpgrader
 }
 
 type AnyType interface {
 	AnyType() string
 }
 
+// AnyUpgrader upgrades payload m of a registered type by one schema version
+// m contains the fields of a struct or map value, or the value of a primitive value with key "@v"
+type AnyUpgrader func(m M) error
+
 // RegisterAny bind typ with prototype
 // E.g.
-//		contents.Register("image", &contents.Image{})
-func RegisterAny(prototype interface{}) {
+//
+//	contents.Register("image", &contents.Image{})
+func RegisterAny(prototype interface{}) error {
+	return RegisterVersionedAny(prototype, 0)
+}
+
+// RegisterVersionedAny binds type name with prototype whose schema version is version.
+// Version is stored next to the type name in JSON. While unmarshaling payload of an older version v,
+// upgraders[v], upgraders[v+1]... are applied in order, so len(upgraders) must be equal to version.
+// Payload without version is of version 0.
+func RegisterVersionedAny(prototype interface{}, version int, upgraders ...AnyUpgrader) error {
+	if prototype == nil {
+		return errors.New("prototype is nil")
+	}
+	if version < 0 {
+		return fmt.Errorf("invalid version %d", version)
+	}
+	if len(upgraders) != version {
+		return fmt.Errorf("expected %d upgraders instead of %d", version, len(upgraders))
+	}
+	for i, u := range upgraders {
+		if u == nil {
+			return fmt.Errorf("upgrader %d is nil", i)
+		}
+	}
+
 	name := GetAnyTypeName(prototype)
 	mu.Lock()
 	defer mu.Unlock()
 	if _, ok := nameToPrototype[name]; ok {
-		log.Fatalf("Duplicate name %s", name)
+		return fmt.Errorf("duplicate name %s", name)
 	}
 
-	nameToPrototype[name] = reflect.TypeOf(prototype)
+	nameToPrototype[name] = &anyEntry{
+		typ:       reflect.TypeOf(prototype),
+		version:   version,
+		upgraders: upgraders,
+	}
+	return nil
 }
 
 func GetAnyTypeName(prototype interface{}) string {
@@ -59,11 +101,11 @@ func GetAnyTypeName(prototype interface{}) string {
 	return CamelToSnake(p.Name())
 }
 
-func getProtoType(typ string) (reflect.Type, bool) {
+func getAnyEntry(typ string) (*anyEntry, bool) {
 	mu.RLock()
 	defer mu.RUnlock()
-	if prototype, ok := nameToPrototype[typ]; ok {
-		return prototype, true
+	if e, ok := nameToPrototype[typ]; ok {
+		return e, true
 	}
 	return nil, false
 }
@@ -172,23 +214,42 @@ func (a *Any) WebPage() *WebPage {
 }
 
 const (
-	keyAnyType = "@t"
-	keyAnyVal  = "@v"
+	keyAnyType    = "@t"
+	keyAnyVal     = "@v"
+	keyAnyVersion = "@ver"
 )
 
 func (a *Any) UnmarshalJSON(b []byte) error {
-	var m map[string]interface{}
-	if err := json.Unmarshal(b, &m); err != nil {
+	var m M
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(&m); err != nil {
 		return err
 	}
+	return a.unmarshalMap(m)
+}
 
+// UnmarshalCodec implements codec.Unmarshaler. Numbers are converted to json.Number,
+// so that upgraders get the same payload as decoded from JSON
+func (a *Any) UnmarshalCodec(v interface{}) error {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("cannot unmarshal %T into Any", v)
+	}
+	return a.unmarshalMap(numbersToJSONNumber(m).(map[string]interface{}))
+}
+
+// unmarshalMap unmarshals m in which numbers are json.Number
+func (a *Any) unmarshalMap(m M) error {
 	typ, _ := m[keyAnyType].(string)
-	pt, found := getProtoType(typ)
+	entry, found := getAnyEntry(typ)
 	if !found {
 		a.val = m[keyAnyVal]
 		if a.val == nil {
 			return errors.New("value is empty")
 		}
+		// numbers are decoded as float64 like json.Unmarshal does
+		a.val = numbersToFloat64(a.val)
 
 		if GetAnyTypeName(a.val) == typ {
 			return nil
@@ -196,17 +257,38 @@ func (a *Any) UnmarshalJSON(b []byte) error {
 		return fmt.Errorf("type doesn't match: %s and %s", typ, GetAnyTypeName(a.val))
 	}
 
+	version, err := getAnyVersion(m)
+	if err != nil {
+		return err
+	}
+	if version > entry.version {
+		return fmt.Errorf("unsupported version %d of %s, latest version is %d", version, typ, entry.version)
+	}
+	delete(m, keyAnyType)
+	delete(m, keyAnyVersion)
+	for ; version < entry.version; version++ {
+		if err = entry.upgraders[version](m); err != nil {
+			return fmt.Errorf("upgrade %s from version %d: %w", typ, version, err)
+		}
+	}
+
+	var b []byte
 	if v, ok := m[keyAnyVal]; ok {
-		b, _ = json.Marshal(v)
+		b, err = json.Marshal(v)
+	} else {
+		b, err = json.Marshal(m)
+	}
+	if err != nil {
+		return err
 	}
 
-	var ptrVal = reflect.New(pt)
+	var ptrVal = reflect.New(entry.typ)
 
 	for val := ptrVal; val.Kind() == reflect.Ptr && val.CanSet(); val = val.Elem() {
 		val.Set(reflect.New(val.Elem().Type()))
 	}
 
-	err := json.Unmarshal(b, ptrVal.Interface())
+	err = json.Unmarshal(b, ptrVal.Interface())
 	if err != nil {
 		return err
 	}
@@ -214,6 +296,119 @@ func (a *Any) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// jsonNumbersToNumbers converts json.Number in v, maps and slices included, into int64, uint64 or float64
+func jsonNumbersToNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = jsonNumbersToNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = jsonNumbersToNumbers(e)
+		}
+	}
+	return v
+}
+
+// numbersToFloat64 converts json.Number in v, maps and slices included, into float64
+func numbersToFloat64(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = numbersToFloat64(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = numbersToFloat64(e)
+		}
+	}
+	return v
+}
+
+// numbersToJSONNumber converts numbers in v, maps and slices included, into json.Number
+func numbersToJSONNumber(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10))
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10))
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64))
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = numbersToJSONNumber(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = numbersToJSONNumber(e)
+		}
+	}
+	return v
+}
+
+func getAnyVersion(m M) (int, error) {
+	v, ok := m[keyAnyVersion]
+	if !ok {
+		return 0, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, fmt.Errorf("invalid version %v", v)
+	}
+	version, err := n.Int64()
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid version %v", v)
+	}
+	return int(version), nil
+}
+
+// MarshalCodec implements codec.Marshaler. Primitive values are kept as is instead of being converted via JSON
+func (a *Any) MarshalCodec() (interface{}, error) {
+	if a == nil || a.val == nil {
+		return nil, nil
+	}
+
+	t := reflect.TypeOf(a.val)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct || t.Kind() == reflect.Map {
+		b, err := a.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+		var m map[string]interface{}
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err = dec.Decode(&m); err != nil {
+			return nil, err
+		}
+		return jsonNumbersToNumbers(m), nil
+	}
+
+	m := map[string]interface{}{
+		keyAnyType: a.TypeName(),
+		keyAnyVal:  a.val,
+	}
+	if e, ok := getAnyEntry(a.TypeName()); ok && e.version > 0 {
+		m[keyAnyVersion] = e.version
+	}
+	return m, nil
+}
+
 func (a *Any) MarshalJSON() ([]byte, error) {
 	if a == nil || a.val == nil {
 		return json.Marshal(nil)
@@ -240,7 +435,11 @@ func (a *Any) MarshalJSON() ([]byte, error) {
 		m[keyAnyVal] = a.val
 	}
 
-	m[keyAnyType] = a.TypeName()
+	typ := a.TypeName()
+	m[keyAnyType] = typ
+	if e, ok := getAnyEntry(typ); ok && e.version > 0 {
+		m[keyAnyVersion] = e.version
+	}
 	return json.Marshal(m)
 }
 
@@ -270,11 +469,11 @@ func (a *Any) Value() (driver.Value, error) {
 }
 
 func init() {
-	RegisterAny(&Image{})
-	RegisterAny(&Video{})
-	RegisterAny(&Audio{})
-	RegisterAny(&WebPage{})
-	RegisterAny(&File{})
+	for _, prototype := range []interface{}{&Image{}, &Video{}, &Audio{}, &WebPage{}, &File{}} {
+		if err := RegisterAny(prototype); err != nil {
+			log.Panic(err)
+		}
+	}
 }
 
 type Image struct {
@@ -0,0 +1,74 @@
+package gox
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	anypb "github.com/golang/protobuf/ptypes/any"
+)
+
+// AnyTypeURLPrefix is the prefix of type urls of values packed into google.protobuf.Any by Any.ToProto.
+// A type url is composed of the prefix and the type name registered with RegisterAny, e.g. type.gopub.io/image
+var AnyTypeURLPrefix = "type.gopub.io/"
+
+// AnyTypeURL returns the type url of prototype in google.protobuf.Any
+func AnyTypeURL(prototype interface{}) string {
+	return AnyTypeURLPrefix + GetAnyTypeName(prototype)
+}
+
+// ToProto packs a into google.protobuf.Any.
+// A protobuf message is packed as is, other values are packed as the JSON form of Any with type url AnyTypeURL(value),
+// so that registered types, versions included, can be restored by NewAnyFromProto.
+func (a *Any) ToProto() (*anypb.Any, error) {
+	if a == nil || a.val == nil {
+		return nil, nil
+	}
+
+	if m, ok := a.val.(proto.Message); ok {
+		pa, err := ptypes.MarshalAny(m)
+		if err != nil {
+			return nil, fmt.Errorf("marshal proto: %w", err)
+		}
+		return pa, nil
+	}
+
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, fmt.Errorf("marshal json: %w", err)
+	}
+	return &anypb.Any{
+		TypeUrl: AnyTypeURL(a.val),
+		Value:   b,
+	}, nil
+}
+
+// NewAnyFromProto unpacks google.protobuf.Any created by Any.ToProto, or any registered protobuf message
+func NewAnyFromProto(pa *anypb.Any) (*Any, error) {
+	if pa == nil {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(pa.TypeUrl, AnyTypeURLPrefix) {
+		typ := strings.TrimPrefix(pa.TypeUrl, AnyTypeURLPrefix)
+		a := new(Any)
+		if err := json.Unmarshal(pa.Value, a); err != nil {
+			return nil, fmt.Errorf("unmarshal json: %w", err)
+		}
+		if a.TypeName() != typ {
+			return nil, fmt.Errorf("type doesn't match: %s and %s", typ, a.TypeName())
+		}
+		return a, nil
+	}
+
+	m, err := ptypes.Empty(pa)
+	if err != nil {
+		return nil, err
+	}
+	if err = ptypes.UnmarshalAny(pa, m); err != nil {
+		return nil, fmt.Errorf("unmarshal proto: %w", err)
+	}
+	return NewAny(m), nil
+}
@@ -3,9 +3,13 @@ package gox_test
 import (
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
+	"github.com/golang/protobuf/proto"
+	anypb "github.com/golang/protobuf/ptypes/any"
+	"github.com/golang/protobuf/ptypes/wrappers"
 	"github.com/gopub/gox"
 )
 
@@ -107,7 +111,7 @@ func TestImage(t *testing.T) {
 	if v2, ok := a2.Val().(*gox.Image); !ok {
 		t.Error("expected Image")
 		t.FailNow()
-	} else if *v != *v2 {
+	} else if !reflect.DeepEqual(v, v2) {
 		t.Error("expected equal image value")
 		t.FailNow()
 	}
@@ -134,7 +138,7 @@ func TestVideo(t *testing.T) {
 	if v2, ok := a2.Val().(*gox.Video); !ok {
 		t.Error("expected Video")
 		t.FailNow()
-	} else if *v.Image != *v2.Image || v.Link != v2.Link || v.Size != v2.Size || v.Format != v2.Format || v.Length != v2.Length {
+	} else if !reflect.DeepEqual(v.Image, v2.Image) || v.Link != v2.Link || v.Size != v2.Size || v.Format != v2.Format || v.Length != v2.Length {
 		t.Error("expected equal video value")
 		t.FailNow()
 	}
@@ -177,3 +181,111 @@ func TestArray(t *testing.T) {
 	//	}
 	//}
 }
+
+func TestUnregisteredAny(t *testing.T) {
+	a := gox.NewAny([]interface{}{1, "a", map[string]interface{}{"n": 2.5, "l": []interface{}{3}}})
+	b, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var a2 *gox.Any
+	if err = json.Unmarshal(b, &a2); err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{1.0, "a", map[string]interface{}{"n": 2.5, "l": []interface{}{3.0}}}
+	if !reflect.DeepEqual(expected, a2.Val()) {
+		t.Fatalf("got %#v", a2.Val())
+	}
+}
+
+type versionedItem struct {
+	Title string `json:"title"`
+	Count int    `json:"count"`
+}
+
+func TestVersionedAny(t *testing.T) {
+	err := gox.RegisterVersionedAny(&versionedItem{}, 2, func(m gox.M) error {
+		m["title"] = m["name"]
+		delete(m, "name")
+		return nil
+	}, func(m gox.M) error {
+		m["count"] = 1
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = gox.RegisterAny(&versionedItem{}); err == nil {
+		t.Fatal("expected duplicate error")
+	}
+
+	for _, s := range []string{
+		`{"@t":"versioned_item","name":"hello"}`,
+		`{"@t":"versioned_item","@ver":1,"title":"hello"}`,
+		`{"@t":"versioned_item","@ver":2,"title":"hello","count":1}`,
+	} {
+		var a *gox.Any
+		if err = json.Unmarshal([]byte(s), &a); err != nil {
+			t.Fatal(s, err)
+		}
+		v, ok := a.Val().(*versionedItem)
+		if !ok {
+			t.Fatalf("%s: expected *versionedItem", s)
+		}
+		if v.Title != "hello" || v.Count != 1 {
+			t.Fatalf("%s: got %+v", s, v)
+		}
+	}
+
+	b, err := json.Marshal(gox.NewAny(&versionedItem{Title: "hi", Count: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"@t":"versioned_item","@ver":2,"count":2,"title":"hi"}` {
+		t.Fatal(string(b))
+	}
+
+	var a *gox.Any
+	if err = json.Unmarshal([]byte(`{"@t":"versioned_item","@ver":3}`), &a); err == nil {
+		t.Fatal("expected unsupported version error")
+	}
+}
+
+func TestAnyProto(t *testing.T) {
+	values := []interface{}{
+		"hello",
+		nextImage(),
+		nextVideo(),
+		&gox.File{Link: "https://www.file.com/a.pdf", Name: "a.pdf", Size: 100, Format: "pdf"},
+		&wrappers.StringValue{Value: "hello"},
+	}
+	for _, v := range values {
+		pa, err := gox.NewAny(v).ToProto()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Log(pa.TypeUrl)
+
+		b, err := proto.Marshal(pa)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pa2 anypb.Any
+		if err = proto.Unmarshal(b, &pa2); err != nil {
+			t.Fatal(err)
+		}
+
+		a, err := gox.NewAnyFromProto(&pa2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m, ok := v.(proto.Message); ok {
+			if !proto.Equal(m, a.Val().(proto.Message)) {
+				t.Fatalf("got %v, want %v", a.Val(), v)
+			}
+		} else if !reflect.DeepEqual(v, a.Val()) {
+			t.Fatalf("got %v, want %v", a.Val(), v)
+		}
+	}
+}
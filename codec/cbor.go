@@ -0,0 +1,391 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// cborCodec implements CBOR defined in RFC 8949
+// Tags are skipped while decoding, indefinite-length items are supported while decoding
+type cborCodec struct{}
+
+const (
+	cborUint   byte = 0 << 5
+	cborNegInt byte = 1 << 5
+	cborBytes  byte = 2 << 5
+	cborText   byte = 3 << 5
+	cborArray  byte = 4 << 5
+	cborMap    byte = 5 << 5
+	cborTag    byte = 6 << 5
+	cborSimple byte = 7 << 5
+
+	cborFalse     byte = cborSimple | 20
+	cborTrue      byte = cborSimple | 21
+	cborNull      byte = cborSimple | 22
+	cborUndefined byte = cborSimple | 23
+	cborFloat16   byte = cborSimple | 25
+	cborFloat32   byte = cborSimple | 26
+	cborFloat64   byte = cborSimple | 27
+	cborBreak     byte = cborSimple | 31
+
+	cborIndefinite = 31
+)
+
+func (cborCodec) Name() string {
+	return "cbor"
+}
+
+func (cborCodec) Marshal(v interface{}) ([]byte, error) {
+	w := &cborWriter{}
+	if err := encode(w, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+func (cborCodec) Unmarshal(data []byte, v interface{}) error {
+	r := &cborReader{data: data}
+	g, err := r.read(0)
+	if err != nil {
+		return fmt.Errorf("cbor: %w", err)
+	}
+	if g == cborBreakValue {
+		return fmt.Errorf("cbor: unexpected break")
+	}
+	if r.pos != len(r.data) {
+		return fmt.Errorf("cbor: %d bytes of trailing data", len(r.data)-r.pos)
+	}
+	return assign(g, v)
+}
+
+type cborWriter struct {
+	buf []byte
+}
+
+var _ writer = (*cborWriter)(nil)
+
+func (w *cborWriter) writeHead(major byte, n uint64) {
+	switch {
+	case n < 24:
+		w.buf = append(w.buf, major|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, major|24, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, major|25)
+		w.buf = appendUint16(w.buf, uint16(n))
+	case n <= math.MaxUint32:
+		w.buf = append(w.buf, major|26)
+		w.buf = appendUint32(w.buf, uint32(n))
+	default:
+		w.buf = append(w.buf, major|27)
+		w.buf = appendUint64(w.buf, n)
+	}
+}
+
+func (w *cborWriter) writeNil() {
+	w.buf = append(w.buf, cborNull)
+}
+
+func (w *cborWriter) writeBool(b bool) {
+	if b {
+		w.buf = append(w.buf, cborTrue)
+	} else {
+		w.buf = append(w.buf, cborFalse)
+	}
+}
+
+func (w *cborWriter) writeInt(i int64) {
+	if i >= 0 {
+		w.writeHead(cborUint, uint64(i))
+	} else {
+		w.writeHead(cborNegInt, uint64(-1-i))
+	}
+}
+
+func (w *cborWriter) writeUint(i uint64) {
+	w.writeHead(cborUint, i)
+}
+
+func (w *cborWriter) writeFloat32(f float32) {
+	w.buf = append(w.buf, cborFloat32)
+	w.buf = appendUint32(w.buf, math.Float32bits(f))
+}
+
+func (w *cborWriter) writeFloat64(f float64) {
+	w.buf = append(w.buf, cborFloat64)
+	w.buf = appendUint64(w.buf, math.Float64bits(f))
+}
+
+func (w *cborWriter) writeString(s string) {
+	w.writeHead(cborText, uint64(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *cborWriter) writeBytes(b []byte) {
+	w.writeHead(cborBytes, uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+func (w *cborWriter) writeArrayHeader(n int) {
+	w.writeHead(cborArray, uint64(n))
+}
+
+func (w *cborWriter) writeMapHeader(n int) {
+	w.writeHead(cborMap, uint64(n))
+}
+
+type cborBreakType struct{}
+
+// cborBreakValue is returned by cborReader.read when break code is read
+var cborBreakValue = cborBreakType{}
+
+type cborReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *cborReader) next(n uint64) ([]byte, error) {
+	if n > uint64(len(r.data)-r.pos) {
+		return nil, errTruncated
+	}
+	b := r.data[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+// readArgument reads the argument encoded by additional info
+func (r *cborReader) readArgument(info byte) (uint64, error) {
+	switch {
+	case info < 24:
+		return uint64(info), nil
+	case info <= 27:
+		b, err := r.next(1 << (info - 24))
+		if err != nil {
+			return 0, err
+		}
+		switch info {
+		case 24:
+			return uint64(b[0]), nil
+		case 25:
+			return uint64(binary.BigEndian.Uint16(b)), nil
+		case 26:
+			return uint64(binary.BigEndian.Uint32(b)), nil
+		default:
+			return binary.BigEndian.Uint64(b), nil
+		}
+	default:
+		return 0, fmt.Errorf("invalid additional info %d", info)
+	}
+}
+
+func (r *cborReader) read(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errTooDeep
+	}
+	b, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	major, info := c&0xe0, c&0x1f
+
+	if major == cborSimple {
+		return r.readSimple(c)
+	}
+
+	if info == cborIndefinite {
+		switch major {
+		case cborBytes, cborText:
+			return r.readIndefiniteString(major, depth)
+		case cborArray:
+			return r.readArray(-1, depth)
+		case cborMap:
+			return r.readMap(-1, depth)
+		default:
+			return nil, fmt.Errorf("invalid indefinite length of major type %d", major>>5)
+		}
+	}
+
+	n, err := r.readArgument(info)
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case cborUint:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return -1 - float64(n), nil
+		}
+		return -1 - int64(n), nil
+	case cborBytes:
+		b, err := r.next(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case cborText:
+		b, err := r.next(n)
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case cborArray:
+		if n > uint64(len(r.data)-r.pos) {
+			return nil, errTruncated
+		}
+		return r.readArray(int(n), depth)
+	case cborMap:
+		if n > uint64(len(r.data)-r.pos) {
+			return nil, errTruncated
+		}
+		return r.readMap(int(n), depth)
+	default:
+		// tag: decode tagged item
+		return r.read(depth + 1)
+	}
+}
+
+func (r *cborReader) readSimple(c byte) (interface{}, error) {
+	switch c {
+	case cborFalse:
+		return false, nil
+	case cborTrue:
+		return true, nil
+	case cborNull, cborUndefined:
+		return nil, nil
+	case cborFloat16:
+		b, err := r.next(2)
+		if err != nil {
+			return nil, err
+		}
+		return float16ToFloat64(binary.BigEndian.Uint16(b)), nil
+	case cborFloat32:
+		b, err := r.next(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case cborFloat64:
+		b, err := r.next(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	case cborBreak:
+		return cborBreakValue, nil
+	default:
+		return nil, fmt.Errorf("unsupported simple value 0x%x", c)
+	}
+}
+
+func float16ToFloat64(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	switch exp {
+	case 0:
+		v = math.Ldexp(mant, -24)
+	case 31:
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	default:
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -v
+	}
+	return v
+}
+
+func (r *cborReader) readIndefiniteString(major byte, depth int) (interface{}, error) {
+	var buf []byte
+	for {
+		v, err := r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		switch chunk := v.(type) {
+		case cborBreakType:
+			if major == cborText {
+				return string(buf), nil
+			}
+			return buf, nil
+		case []byte:
+			if major != cborBytes {
+				return nil, fmt.Errorf("invalid chunk type %T", v)
+			}
+			buf = append(buf, chunk...)
+		case string:
+			if major != cborText {
+				return nil, fmt.Errorf("invalid chunk type %T", v)
+			}
+			buf = append(buf, chunk...)
+		default:
+			return nil, fmt.Errorf("invalid chunk type %T", v)
+		}
+	}
+}
+
+// readArray reads n items, or items until break if n is negative
+func (r *cborReader) readArray(n int, depth int) (interface{}, error) {
+	var a []interface{}
+	if n >= 0 {
+		a = make([]interface{}, 0, n)
+	} else {
+		a = []interface{}{}
+	}
+	for i := 0; n < 0 || i < n; i++ {
+		v, err := r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if v == cborBreakValue {
+			if n < 0 {
+				return a, nil
+			}
+			return nil, fmt.Errorf("unexpected break")
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+// readMap reads n pairs, or pairs until break if n is negative
+func (r *cborReader) readMap(n int, depth int) (interface{}, error) {
+	m := make(map[string]interface{})
+	for i := 0; n < 0 || i < n; i++ {
+		k, err := r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if k == cborBreakValue {
+			if n < 0 {
+				return m, nil
+			}
+			return nil, fmt.Errorf("unexpected break")
+		}
+		ks, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("unsupported map key type %T", k)
+		}
+		v, err := r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if v == cborBreakValue {
+			return nil, fmt.Errorf("unexpected break")
+		}
+		m[ks] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,401 @@
+// Package codec provides JSON, MessagePack and CBOR codecs which encode values consistently.
+//
+// Binary codecs support nil, bool, numbers, strings, []byte, slices, arrays and maps with string keys natively.
+// Types implementing Marshaler and Unmarshaler, e.g. gox.Any, gox.M and sql.Map, convert themselves natively.
+// Other values, e.g. structs and types implementing json.Marshaler, are converted via their JSON form,
+// so json tags and custom JSON marshaling are respected.
+//
+// Numbers decoded into interface{}, map[string]interface{} and Unmarshaler are int64 for integers, uint64 for
+// integers larger than math.MaxInt64 and float64 for others, regardless of codec.
+// As JSON doesn't distinguish 1.0 from 1, integral floats are decoded as int64 by JSON codec.
+package codec
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+type Codec interface {
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Marshaler is implemented by types which convert themselves into values which can be encoded natively,
+// e.g. nil, bool, numbers, strings, []byte, []interface{} and map[string]interface{}
+type Marshaler interface {
+	MarshalCodec() (interface{}, error)
+}
+
+// Unmarshaler is implemented by types which assign themselves from decoded values: nil, bool, int64, uint64,
+// float64, string, []byte, []interface{} and map[string]interface{}.
+// JSON codec decodes strings instead of []byte
+type Unmarshaler interface {
+	UnmarshalCodec(v interface{}) error
+}
+
+var (
+	JSON        Codec = jsonCodec{}
+	MessagePack Codec = msgpackCodec{}
+	CBOR        Codec = cborCodec{}
+)
+
+// Get returns codec by name: json, msgpack or cbor
+func Get(name string) Codec {
+	switch name {
+	case "json":
+		return JSON
+	case "msgpack":
+		return MessagePack
+	case "cbor":
+		return CBOR
+	default:
+		return nil
+	}
+}
+
+// maxDepth limits nesting of decoded values
+const maxDepth = 512
+
+var (
+	errTruncated = errors.New("unexpected end of data")
+	errTooDeep   = errors.New("exceeded max depth")
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes numbers into int64, uint64 or float64 like binary codecs if v is interface{},
+// map[string]interface{} or Unmarshaler, otherwise it behaves as json.Unmarshal
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || !isGenericTarget(rv) {
+		if err := dec.Decode(v); err != nil {
+			return err
+		}
+		return checkJSONEOF(dec)
+	}
+
+	dec.UseNumber()
+	var g interface{}
+	if err := dec.Decode(&g); err != nil {
+		return err
+	}
+	if err := checkJSONEOF(dec); err != nil {
+		return err
+	}
+	g, err := normalizeNumbers(g)
+	if err != nil {
+		return err
+	}
+	return assign(g, v)
+}
+
+func checkJSONEOF(dec *json.Decoder) error {
+	if _, err := dec.Token(); err != io.EOF {
+		return errors.New("json: trailing data")
+	}
+	return nil
+}
+
+// normalizeNumbers converts json.Number in g into int64, uint64 or float64
+func normalizeNumbers(g interface{}) (interface{}, error) {
+	switch v := g.(type) {
+	case json.Number:
+		if i, err := strconv.ParseInt(string(v), 10, 64); err == nil {
+			return i, nil
+		}
+		if u, err := strconv.ParseUint(string(v), 10, 64); err == nil {
+			return u, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s", v)
+		}
+		return f, nil
+	case []interface{}:
+		for i, e := range v {
+			n, err := normalizeNumbers(e)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = n
+		}
+	case map[string]interface{}:
+		for k, e := range v {
+			n, err := normalizeNumbers(e)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = n
+		}
+	}
+	return g, nil
+}
+
+// writer writes values of the generic data model in a binary format
+type writer interface {
+	writeNil()
+	writeBool(b bool)
+	writeInt(i int64)
+	writeUint(i uint64)
+	writeFloat32(f float32)
+	writeFloat64(f float64)
+	writeString(s string)
+	writeBytes(b []byte)
+	writeArrayHeader(n int)
+	writeMapHeader(n int)
+}
+
+var (
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	bytesType           = reflect.TypeOf([]byte(nil))
+)
+
+func encode(w writer, v reflect.Value) error {
+	if !v.IsValid() {
+		w.writeNil()
+		return nil
+	}
+
+	t := v.Type()
+	if v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(t).Implements(marshalerType) {
+		return encodeMarshaler(w, v.Addr().Interface().(Marshaler))
+	}
+	if t.Implements(marshalerType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			w.writeNil()
+			return nil
+		}
+		return encodeMarshaler(w, v.Interface().(Marshaler))
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return encodeJSON(w, v.Addr().Interface())
+	}
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			w.writeNil()
+			return nil
+		}
+		return encodeJSON(w, v.Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			w.writeNil()
+			return nil
+		}
+		return encode(w, v.Elem())
+	case reflect.Bool:
+		w.writeBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		w.writeInt(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		w.writeUint(v.Uint())
+	case reflect.Float32:
+		w.writeFloat32(float32(v.Float()))
+	case reflect.Float64:
+		w.writeFloat64(v.Float())
+	case reflect.String:
+		w.writeString(v.String())
+	case reflect.Slice:
+		if v.IsNil() {
+			w.writeNil()
+			return nil
+		}
+		if t.Elem().Kind() == reflect.Uint8 {
+			w.writeBytes(v.Convert(bytesType).Bytes())
+			return nil
+		}
+		return encodeArray(w, v)
+	case reflect.Array:
+		return encodeArray(w, v)
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return encodeJSON(w, v.Interface())
+		}
+		if v.IsNil() {
+			w.writeNil()
+			return nil
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		w.writeMapHeader(len(keys))
+		for _, k := range keys {
+			w.writeString(k.String())
+			if err := encode(w, v.MapIndex(k)); err != nil {
+				return err
+			}
+		}
+	default:
+		return encodeJSON(w, v.Interface())
+	}
+	return nil
+}
+
+func encodeArray(w writer, v reflect.Value) error {
+	w.writeArrayHeader(v.Len())
+	for i := 0; i < v.Len(); i++ {
+		if err := encode(w, v.Index(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeMarshaler(w writer, m Marshaler) error {
+	g, err := m.MarshalCodec()
+	if err != nil {
+		return err
+	}
+	return encode(w, reflect.ValueOf(g))
+}
+
+// encodeJSON encodes v via its JSON form
+func encodeJSON(w writer, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var g interface{}
+	if err = dec.Decode(&g); err != nil {
+		return err
+	}
+	return encodeGeneric(w, g)
+}
+
+// encodeGeneric encodes values decoded by encoding/json with UseNumber
+func encodeGeneric(w writer, v interface{}) error {
+	switch g := v.(type) {
+	case nil:
+		w.writeNil()
+	case bool:
+		w.writeBool(g)
+	case string:
+		w.writeString(g)
+	case json.Number:
+		if i, err := g.Int64(); err == nil {
+			w.writeInt(i)
+		} else if f, err := g.Float64(); err == nil {
+			w.writeFloat64(f)
+		} else {
+			return fmt.Errorf("invalid number %s", g)
+		}
+	case []interface{}:
+		w.writeArrayHeader(len(g))
+		for _, e := range g {
+			if err := encodeGeneric(w, e); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(g))
+		for k := range g {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		w.writeMapHeader(len(keys))
+		for _, k := range keys {
+			w.writeString(k)
+			if err := encodeGeneric(w, g[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	return nil
+}
+
+// isGenericTarget reports whether decoded values are assigned to pointer v directly or by Unmarshaler
+func isGenericTarget(v reflect.Value) bool {
+	t := v.Type()
+	e := t.Elem()
+	switch {
+	case t.Implements(unmarshalerType):
+		return true
+	case e.Kind() == reflect.Ptr && e.Implements(unmarshalerType):
+		return true
+	case e.Kind() == reflect.Interface && e.NumMethod() == 0:
+		return true
+	case e.Kind() == reflect.Map && e.Key().Kind() == reflect.String &&
+		e.Elem().Kind() == reflect.Interface && e.Elem().NumMethod() == 0 && !t.Implements(jsonUnmarshalerType):
+		return true
+	default:
+		return false
+	}
+}
+
+// assign assigns generic value g to v which must be a non-nil pointer.
+// g is assigned by Unmarshaler, or directly if v points to interface{} or map[string]interface{}, otherwise via JSON
+func assign(g interface{}, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("non-nil pointer is required instead of %T", v)
+	}
+
+	if u, ok := v.(Unmarshaler); ok {
+		return u.UnmarshalCodec(g)
+	}
+
+	e := rv.Elem()
+	switch {
+	case e.Kind() == reflect.Ptr && e.Type().Implements(unmarshalerType):
+		if g == nil {
+			e.Set(reflect.Zero(e.Type()))
+			return nil
+		}
+		if e.IsNil() {
+			e.Set(reflect.New(e.Type().Elem()))
+		}
+		return e.Interface().(Unmarshaler).UnmarshalCodec(g)
+	case e.Kind() == reflect.Interface && e.NumMethod() == 0:
+		if g == nil {
+			e.Set(reflect.Zero(e.Type()))
+		} else {
+			e.Set(reflect.ValueOf(g))
+		}
+		return nil
+	case e.Kind() == reflect.Map && e.Type().Key().Kind() == reflect.String &&
+		e.Type().Elem().Kind() == reflect.Interface && e.Type().Elem().NumMethod() == 0 &&
+		!rv.Type().Implements(jsonUnmarshalerType):
+		switch m := g.(type) {
+		case nil:
+			e.Set(reflect.Zero(e.Type()))
+			return nil
+		case map[string]interface{}:
+			e.Set(reflect.ValueOf(m).Convert(e.Type()))
+			return nil
+		}
+	}
+
+	b, err := json.Marshal(g)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
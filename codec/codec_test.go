@@ -0,0 +1,195 @@
+package codec_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/gopub/gox"
+	"github.com/gopub/gox/codec"
+	"github.com/gopub/gox/sql"
+	"github.com/stretchr/testify/require"
+)
+
+var codecs = []codec.Codec{codec.JSON, codec.MessagePack, codec.CBOR}
+
+func TestAny(t *testing.T) {
+	image := &gox.Image{
+		Link:      "https://www.image.com/1.png",
+		Width:     200,
+		Height:    800,
+		Format:    "png",
+		Size:      1024,
+		Name:      "1.png",
+		Thumbnail: "https://www.image.com/1_thumb.png",
+	}
+	values := []interface{}{
+		"hello",
+		int64(1) << 60,
+		3.14,
+		true,
+		image,
+		&gox.Video{Link: "https://www.video.com/1.mp4", Format: "mp4", Length: 120, Size: 4096, Image: image, Name: "1.mp4"},
+		&gox.Audio{Link: "https://www.audio.com/1.mp3", Format: "mp3", Length: 60, Size: 512, Name: "1.mp3"},
+		&gox.File{Link: "https://www.file.com/1.pdf", Name: "1.pdf", Size: 2048, Format: "pdf"},
+		&gox.WebPage{Title: "Title", Summary: "Summary", Image: image, Link: "https://www.page.com"},
+	}
+
+	for _, c := range codecs {
+		t.Run(c.Name(), func(t *testing.T) {
+			for _, v := range values {
+				b, err := c.Marshal(gox.NewAny(v))
+				require.NoError(t, err)
+
+				var a *gox.Any
+				require.NoError(t, c.Unmarshal(b, &a))
+				require.Equal(t, v, a.Val())
+			}
+
+			list := []*gox.Any{gox.NewAny(image), gox.NewAny("hello")}
+			b, err := c.Marshal(list)
+			require.NoError(t, err)
+			var list2 []*gox.Any
+			require.NoError(t, c.Unmarshal(b, &list2))
+			require.Equal(t, list, list2)
+		})
+	}
+}
+
+func TestMap(t *testing.T) {
+	for _, c := range codecs {
+		t.Run(c.Name(), func(t *testing.T) {
+			m := gox.M{
+				"id":    int64(1)<<62 + 1,
+				"name":  "hello",
+				"ok":    true,
+				"score": 9.5,
+				"tags":  []interface{}{"a", "b"},
+				"user":  map[string]interface{}{"id": int64(-2), "nil": nil},
+			}
+			b, err := c.Marshal(m)
+			require.NoError(t, err)
+
+			var m2 gox.M
+			require.NoError(t, c.Unmarshal(b, &m2))
+			require.Equal(t, m.Int64("id"), m2.Int64("id"))
+			require.Equal(t, m.JSON(), m2.JSON())
+
+			var sm sql.Map
+			require.NoError(t, c.Unmarshal(b, &sm))
+			b2, err := c.Marshal(sm)
+			require.NoError(t, err)
+			require.Equal(t, b, b2)
+		})
+	}
+}
+
+func TestBinaryFormat(t *testing.T) {
+	v := map[string]interface{}{"a": 1, "b": []interface{}{"x", nil, true, -1}, "c": []byte{1}}
+	b, err := codec.MessagePack.Marshal(v)
+	require.NoError(t, err)
+	require.Equal(t, "83a16101a16294a178c0c3ffa163c40101", hex.EncodeToString(b))
+
+	b, err = codec.CBOR.Marshal(v)
+	require.NoError(t, err)
+	require.Equal(t, "a36161016162846178f6f52061634101", hex.EncodeToString(b))
+
+	// indefinite-length array and map
+	var g interface{}
+	data, _ := hex.DecodeString("bf61619f0102ff61627f61786179ffff")
+	require.NoError(t, codec.CBOR.Unmarshal(data, &g))
+	require.Equal(t, map[string]interface{}{"a": []interface{}{int64(1), int64(2)}, "b": "xy"}, g)
+
+	require.Error(t, codec.MessagePack.Unmarshal([]byte{0x92, 0x01}, &g))
+	require.Error(t, codec.CBOR.Unmarshal([]byte{0x82, 0x01}, &g))
+}
+
+// TestConsistency checks that all codecs decode the same values into interface{}, M, sql.Map and Any
+func TestConsistency(t *testing.T) {
+	m := gox.M{
+		"int":    int64(1)<<62 + 1,
+		"neg":    int64(-3),
+		"uint":   uint64(1) << 63,
+		"float":  2.5,
+		"string": "s",
+		"bool":   true,
+		"nil":    nil,
+		"list":   []interface{}{int64(1), 0.5, "a", map[string]interface{}{"k": int64(2)}},
+		"map":    map[string]interface{}{"n": int64(-1) << 40},
+	}
+	image := &gox.Image{Link: "https://www.image.com/1.png", Width: 200, Height: 800}
+	anys := []*gox.Any{gox.NewAny(image), gox.NewAny(int64(1) << 60), gox.NewAny("hello"), gox.NewAny(1.5)}
+
+	results := make(map[string][]interface{})
+	for _, c := range codecs {
+		t.Run(c.Name(), func(t *testing.T) {
+			b, err := c.Marshal(m)
+			require.NoError(t, err)
+
+			var g interface{}
+			require.NoError(t, c.Unmarshal(b, &g))
+			require.Equal(t, map[string]interface{}(m), g)
+
+			var m2 gox.M
+			require.NoError(t, c.Unmarshal(b, &m2))
+			require.Equal(t, m, m2)
+
+			var sm sql.Map
+			require.NoError(t, c.Unmarshal(b, &sm))
+			require.Equal(t, sql.Map(m), sm)
+
+			b, err = c.Marshal(nil)
+			require.NoError(t, err)
+			require.NoError(t, c.Unmarshal(b, &m2))
+			require.Nil(t, m2)
+
+			for _, a := range anys {
+				b, err = c.Marshal(a)
+				require.NoError(t, err)
+				var a2 *gox.Any
+				require.NoError(t, c.Unmarshal(b, &a2))
+				require.Equal(t, a.Val(), a2.Val())
+			}
+
+			// Any nested in M is decoded as a tagged map
+			b, err = c.Marshal(gox.M{"image": anys[0]})
+			require.NoError(t, err)
+			require.NoError(t, c.Unmarshal(b, &m2))
+			require.Equal(t, gox.M{"image": map[string]interface{}{
+				"@t": "image", "link": image.Link, "w": int64(200), "h": int64(800),
+			}}, m2)
+
+			results[c.Name()] = []interface{}{g, m2}
+		})
+	}
+	require.Equal(t, results["json"], results["msgpack"])
+	require.Equal(t, results["json"], results["cbor"])
+}
+
+type versionedCounter struct {
+	Count int64 `json:"count"`
+}
+
+func TestVersionedAny(t *testing.T) {
+	// upgraders get json.Number regardless of codec
+	err := gox.RegisterVersionedAny(&versionedCounter{}, 1, func(m gox.M) error {
+		n, ok := m["n"].(json.Number)
+		if !ok {
+			return fmt.Errorf("expected json.Number instead of %T", m["n"])
+		}
+		m["count"] = n
+		return nil
+	})
+	require.NoError(t, err)
+
+	for _, c := range codecs {
+		t.Run(c.Name(), func(t *testing.T) {
+			b, err := c.Marshal(map[string]interface{}{"@t": "versioned_counter", "n": int64(1) << 60})
+			require.NoError(t, err)
+			var a *gox.Any
+			require.NoError(t, c.Unmarshal(b, &a))
+			require.Equal(t, &versionedCounter{Count: 1 << 60}, a.Val())
+		})
+	}
+}
@@ -0,0 +1,339 @@
+package codec
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// msgpackCodec implements MessagePack, see https://github.com/msgpack/msgpack/blob/master/spec.md
+// Extension types are not supported
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string {
+	return "msgpack"
+}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	w := &msgpackWriter{}
+	if err := encode(w, reflect.ValueOf(v)); err != nil {
+		return nil, err
+	}
+	return w.buf, nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	r := &msgpackReader{data: data}
+	g, err := r.read(0)
+	if err != nil {
+		return fmt.Errorf("msgpack: %w", err)
+	}
+	if r.pos != len(r.data) {
+		return fmt.Errorf("msgpack: %d bytes of trailing data", len(r.data)-r.pos)
+	}
+	return assign(g, v)
+}
+
+type msgpackWriter struct {
+	buf []byte
+}
+
+var _ writer = (*msgpackWriter)(nil)
+
+func (w *msgpackWriter) writeNil() {
+	w.buf = append(w.buf, 0xc0)
+}
+
+func (w *msgpackWriter) writeBool(b bool) {
+	if b {
+		w.buf = append(w.buf, 0xc3)
+	} else {
+		w.buf = append(w.buf, 0xc2)
+	}
+}
+
+func (w *msgpackWriter) writeInt(i int64) {
+	switch {
+	case i >= 0:
+		w.writeUint(uint64(i))
+	case i >= -32:
+		w.buf = append(w.buf, byte(i))
+	case i >= math.MinInt8:
+		w.buf = append(w.buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		w.buf = append(w.buf, 0xd1)
+		w.buf = appendUint16(w.buf, uint16(i))
+	case i >= math.MinInt32:
+		w.buf = append(w.buf, 0xd2)
+		w.buf = appendUint32(w.buf, uint32(i))
+	default:
+		w.buf = append(w.buf, 0xd3)
+		w.buf = appendUint64(w.buf, uint64(i))
+	}
+}
+
+func (w *msgpackWriter) writeUint(i uint64) {
+	switch {
+	case i <= 0x7f:
+		w.buf = append(w.buf, byte(i))
+	case i <= math.MaxUint8:
+		w.buf = append(w.buf, 0xcc, byte(i))
+	case i <= math.MaxUint16:
+		w.buf = append(w.buf, 0xcd)
+		w.buf = appendUint16(w.buf, uint16(i))
+	case i <= math.MaxUint32:
+		w.buf = append(w.buf, 0xce)
+		w.buf = appendUint32(w.buf, uint32(i))
+	default:
+		w.buf = append(w.buf, 0xcf)
+		w.buf = appendUint64(w.buf, i)
+	}
+}
+
+func (w *msgpackWriter) writeFloat32(f float32) {
+	w.buf = append(w.buf, 0xca)
+	w.buf = appendUint32(w.buf, math.Float32bits(f))
+}
+
+func (w *msgpackWriter) writeFloat64(f float64) {
+	w.buf = append(w.buf, 0xcb)
+	w.buf = appendUint64(w.buf, math.Float64bits(f))
+}
+
+func (w *msgpackWriter) writeString(s string) {
+	n := len(s)
+	switch {
+	case n <= 31:
+		w.buf = append(w.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, 0xda)
+		w.buf = appendUint16(w.buf, uint16(n))
+	default:
+		w.buf = append(w.buf, 0xdb)
+		w.buf = appendUint32(w.buf, uint32(n))
+	}
+	w.buf = append(w.buf, s...)
+}
+
+func (w *msgpackWriter) writeBytes(b []byte) {
+	n := len(b)
+	switch {
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, 0xc5)
+		w.buf = appendUint16(w.buf, uint16(n))
+	default:
+		w.buf = append(w.buf, 0xc6)
+		w.buf = appendUint32(w.buf, uint32(n))
+	}
+	w.buf = append(w.buf, b...)
+}
+
+func (w *msgpackWriter) writeArrayHeader(n int) {
+	switch {
+	case n <= 15:
+		w.buf = append(w.buf, 0x90|byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, 0xdc)
+		w.buf = appendUint16(w.buf, uint16(n))
+	default:
+		w.buf = append(w.buf, 0xdd)
+		w.buf = appendUint32(w.buf, uint32(n))
+	}
+}
+
+func (w *msgpackWriter) writeMapHeader(n int) {
+	switch {
+	case n <= 15:
+		w.buf = append(w.buf, 0x80|byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, 0xde)
+		w.buf = appendUint16(w.buf, uint16(n))
+	default:
+		w.buf = append(w.buf, 0xdf)
+		w.buf = appendUint32(w.buf, uint32(n))
+	}
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	return append(b, byte(v>>56), byte(v>>48), byte(v>>40), byte(v>>32), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+type msgpackReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *msgpackReader) next(n int) ([]byte, error) {
+	if n < 0 || n > len(r.data)-r.pos {
+		return nil, errTruncated
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+func (r *msgpackReader) readUint(size int) (uint64, error) {
+	b, err := r.next(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 1:
+		return uint64(b[0]), nil
+	case 2:
+		return uint64(binary.BigEndian.Uint16(b)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(b)), nil
+	default:
+		return binary.BigEndian.Uint64(b), nil
+	}
+}
+
+func (r *msgpackReader) read(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errTooDeep
+	}
+	b, err := r.next(1)
+	if err != nil {
+		return nil, err
+	}
+	c := b[0]
+	switch {
+	case c <= 0x7f:
+		return int64(c), nil
+	case c >= 0xe0:
+		return int64(int8(c)), nil
+	case c&0xe0 == 0xa0:
+		return r.readString(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return r.readArray(int(c&0x0f), depth)
+	case c&0xf0 == 0x80:
+		return r.readMap(int(c&0x0f), depth)
+	}
+
+	switch c {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := r.readUint(1 << (c - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		if u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(u), nil
+	case 0xd0:
+		u, err := r.readUint(1)
+		return int64(int8(u)), err
+	case 0xd1:
+		u, err := r.readUint(2)
+		return int64(int16(u)), err
+	case 0xd2:
+		u, err := r.readUint(4)
+		return int64(int32(u)), err
+	case 0xd3:
+		u, err := r.readUint(8)
+		return int64(u), err
+	case 0xca:
+		u, err := r.readUint(4)
+		return float64(math.Float32frombits(uint32(u))), err
+	case 0xcb:
+		u, err := r.readUint(8)
+		return math.Float64frombits(u), err
+	case 0xd9, 0xda, 0xdb:
+		n, err := r.readUint(1 << (c - 0xd9))
+		if err != nil {
+			return nil, err
+		}
+		return r.readString(int(n))
+	case 0xc4, 0xc5, 0xc6:
+		n, err := r.readUint(1 << (c - 0xc4))
+		if err != nil {
+			return nil, err
+		}
+		b, err := r.next(int(n))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), b...), nil
+	case 0xdc, 0xdd:
+		n, err := r.readUint(2 << (c - 0xdc))
+		if err != nil {
+			return nil, err
+		}
+		return r.readArray(int(n), depth)
+	case 0xde, 0xdf:
+		n, err := r.readUint(2 << (c - 0xde))
+		if err != nil {
+			return nil, err
+		}
+		return r.readMap(int(n), depth)
+	default:
+		return nil, fmt.Errorf("unsupported type 0x%x", c)
+	}
+}
+
+func (r *msgpackReader) readString(n int) (string, error) {
+	b, err := r.next(n)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (r *msgpackReader) readArray(n int, depth int) (interface{}, error) {
+	// each element takes at least one byte
+	if n > len(r.data)-r.pos {
+		return nil, errTruncated
+	}
+	a := make([]interface{}, n)
+	for i := range a {
+		v, err := r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		a[i] = v
+	}
+	return a, nil
+}
+
+func (r *msgpackReader) readMap(n int, depth int) (interface{}, error) {
+	if n > len(r.data)-r.pos {
+		return nil, errTruncated
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		ks, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("unsupported map key type %T", k)
+		}
+		v, err := r.read(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		m[ks] = v
+	}
+	return m, nil
+}
@@ -16,27 +16,15 @@ const (
 	CKUserID      ContextKey = "gox_user_id"
 	CKTraceID     ContextKey = "gox_trace_id"
 	CKUser        ContextKey = "gox_user"
+	CKLocale      ContextKey = "gox_locale"
 )
 
+// String returns name of k which is registered by RegisterContextKey
 func (k ContextKey) String() string {
-	switch k {
-	case CKDeviceID:
-		return "device_id"
-	case CKRemoteAddr:
-		return "remote_addr"
-	case CKLocation:
-		return "location"
-	case CKAccessToken:
-		return "access_token"
-	case CKUserID:
-		return "user_id"
-	case CKTraceID:
-		return "trace_id"
-	case CKUser:
-		return "user"
-	default:
-		return "none"
+	if e, ok := getContextKeyEntry(k); ok {
+		return e.name
 	}
+	return "none"
 }
 
 func GetUserID(ctx context.Context) int64 {
@@ -107,6 +95,18 @@ func WithTraceID(ctx context.Context, traceID string) context.Context {
 	return context.WithValue(ctx, CKTraceID, traceID)
 }
 
+func GetLocale(ctx context.Context) string {
+	locale, _ := ctx.Value(CKLocale).(string)
+	return locale
+}
+
+func WithLocale(ctx context.Context, locale string) context.Context {
+	if len(locale) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, CKLocale, locale)
+}
+
 func GetLocation(ctx context.Context) *geo.Point {
 	id, _ := ctx.Value(CKLocation).(*geo.Point)
 	return id
@@ -119,25 +119,17 @@ func WithLocation(ctx context.Context, location *geo.Point) context.Context {
 	return context.WithValue(ctx, CKLocation, location)
 }
 
+// DetachedContext returns a background context with values whose propagation policy contains PropagateDetach.
+// Empty values, e.g. "", nil and non-positive user id, are not copied
 func DetachedContext(ctx context.Context) context.Context {
 	newCtx := context.Background()
-	if token := GetAccessToken(ctx); len(token) > 0 {
-		newCtx = WithAccessToken(newCtx, token)
-	}
-	if deviceID := GetDeviceID(ctx); len(deviceID) > 0 {
-		newCtx = WithDeviceID(newCtx, deviceID)
-	}
-	if c := GetLocation(ctx); c != nil {
-		newCtx = WithLocation(newCtx, c)
-	}
-	if addr := GetRemoteAddr(ctx); len(addr) > 0 {
-		newCtx = WithRemoteAddr(newCtx, addr)
-	}
-	if traceID := GetTraceID(ctx); len(traceID) > 0 {
-		newCtx = WithTraceID(newCtx, traceID)
-	}
-	if loginID := GetUserID(ctx); loginID > 0 {
-		newCtx = WithUserID(newCtx, loginID)
+	for _, e := range getContextKeyEntries() {
+		if e.propagation&PropagateDetach == 0 {
+			continue
+		}
+		if v := ctx.Value(e.key); !e.isEmpty(v) {
+			newCtx = context.WithValue(newCtx, e.key, v)
+		}
 	}
 	return newCtx
 }
@@ -0,0 +1,307 @@
+package gox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gopub/gox/geo"
+	"github.com/gopub/log"
+)
+
+// ContextPropagation decides where a context value is carried to
+type ContextPropagation int
+
+const (
+	// PropagateDetach copies value in DetachedContext
+	PropagateDetach ContextPropagation = 1 << iota
+	// PropagateGRPC sends value in grpc metadata and reads it in server interceptors
+	PropagateGRPC
+	// PropagateHTTP sends value in http header by SetContextHeader and reads it by ContextHeaderMiddleware
+	PropagateHTTP
+
+	PropagateAll = PropagateDetach | PropagateGRPC | PropagateHTTP
+)
+
+// ContextCodec converts context value to string which is sent in grpc metadata or http header
+type ContextCodec[T any] struct {
+	Encode func(v T) (string, error)
+	Decode func(s string) (T, error)
+}
+
+var StringContextCodec = &ContextCodec[string]{
+	Encode: func(v string) (string, error) {
+		return v, nil
+	},
+	Decode: func(s string) (string, error) {
+		return s, nil
+	},
+}
+
+var Int64ContextCodec = &ContextCodec[int64]{
+	Encode: func(v int64) (string, error) {
+		return strconv.FormatInt(v, 10), nil
+	},
+	Decode: func(s string) (int64, error) {
+		return strconv.ParseInt(s, 10, 64)
+	},
+}
+
+// LocationContextCodec encodes location as "lat,lng"
+var LocationContextCodec = &ContextCodec[*geo.Point]{
+	Encode: func(v *geo.Point) (string, error) {
+		if v == nil {
+			return "", nil
+		}
+		return fmt.Sprintf("%f,%f", v.Y, v.X), nil
+	},
+	Decode: func(s string) (*geo.Point, error) {
+		var lat, lng float64
+		if _, err := fmt.Sscanf(s, "%f,%f", &lat, &lng); err != nil {
+			return nil, err
+		}
+		return &geo.Point{X: lng, Y: lat}, nil
+	},
+}
+
+// JSONContextCodec encodes value as JSON
+func JSONContextCodec[T any]() *ContextCodec[T] {
+	return &ContextCodec[T]{
+		Encode: func(v T) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		Decode: func(s string) (T, error) {
+			var v T
+			err := json.Unmarshal([]byte(s), &v)
+			return v, err
+		},
+	}
+}
+
+type contextKeyEntry struct {
+	key         ContextKey
+	name        string
+	header      string
+	propagation ContextPropagation
+	encode      func(v interface{}) (string, error)
+	decode      func(s string) (interface{}, error)
+	// isEmpty reports whether v is not copied by DetachedContext
+	isEmpty func(v interface{}) bool
+}
+
+func isZeroContextValue(v interface{}) bool {
+	return v == nil || reflect.ValueOf(v).IsZero()
+}
+
+var contextKeyMu sync.RWMutex
+var contextKeyEntries []*contextKeyEntry
+
+// TypedContextKey is a registered context key of values of type T
+type TypedContextKey[T any] struct {
+	entry *contextKeyEntry
+}
+
+// RegisterContextKey registers a context key named name. The name is used as grpc metadata key,
+// and http header is derived from it, e.g. tenant_id is sent in header X-Tenant-Id.
+// codec is required if value is propagated over grpc or http
+func RegisterContextKey[T any](name string, codec *ContextCodec[T], propagation ContextPropagation) (*TypedContextKey[T], error) {
+	return registerContextKey(ContextKey("gox_"+name), name, codec, propagation)
+}
+
+// MustRegisterContextKey is like RegisterContextKey but panics if key cannot be registered
+func MustRegisterContextKey[T any](name string, codec *ContextCodec[T], propagation ContextPropagation) *TypedContextKey[T] {
+	k, err := RegisterContextKey(name, codec, propagation)
+	if err != nil {
+		panic(err)
+	}
+	return k
+}
+
+func registerContextKey[T any](key ContextKey, name string, codec *ContextCodec[T], propagation ContextPropagation) (*TypedContextKey[T], error) {
+	if name == "" {
+		return nil, errors.New("name is empty")
+	}
+	if name != strings.ToLower(name) {
+		return nil, fmt.Errorf("name %s is not lowercase", name)
+	}
+	if propagation&(PropagateGRPC|PropagateHTTP) != 0 && (codec == nil || codec.Encode == nil || codec.Decode == nil) {
+		return nil, fmt.Errorf("codec of %s is required", name)
+	}
+	e := &contextKeyEntry{
+		key:         key,
+		name:        name,
+		header:      "X-" + http.CanonicalHeaderKey(strings.ReplaceAll(name, "_", "-")),
+		propagation: propagation,
+		isEmpty:     isZeroContextValue,
+	}
+	if codec != nil {
+		e.encode = func(v interface{}) (string, error) {
+			tv, ok := v.(T)
+			if !ok {
+				return "", fmt.Errorf("invalid type %T", v)
+			}
+			return codec.Encode(tv)
+		}
+		e.decode = func(s string) (interface{}, error) {
+			return codec.Decode(s)
+		}
+	}
+
+	contextKeyMu.Lock()
+	defer contextKeyMu.Unlock()
+	for _, ce := range contextKeyEntries {
+		if ce.name == name || ce.key == key {
+			return nil, fmt.Errorf("duplicate context key %s", name)
+		}
+	}
+	contextKeyEntries = append(contextKeyEntries, e)
+	return &TypedContextKey[T]{entry: e}, nil
+}
+
+// SetContextPropagation changes propagation of a registered key. Built-in keys keep their original propagation,
+// e.g. trace id is only copied by DetachedContext, use it to opt in wider propagation:
+//
+//	gox.SetContextPropagation(gox.CKTraceID, gox.PropagateAll)
+//
+// It should be called during initialization
+func SetContextPropagation(key ContextKey, propagation ContextPropagation) error {
+	contextKeyMu.Lock()
+	defer contextKeyMu.Unlock()
+	for i, e := range contextKeyEntries {
+		if e.key != key {
+			continue
+		}
+		if propagation&(PropagateGRPC|PropagateHTTP) != 0 && (e.encode == nil || e.decode == nil) {
+			return fmt.Errorf("codec of %s is required", e.name)
+		}
+		// copy on write as entries are read without lock
+		ne := *e
+		ne.propagation = propagation
+		entries := make([]*contextKeyEntry, len(contextKeyEntries))
+		copy(entries, contextKeyEntries)
+		entries[i] = &ne
+		contextKeyEntries = entries
+		return nil
+	}
+	return fmt.Errorf("context key %s is not registered", string(key))
+}
+
+func getContextKeyEntries() []*contextKeyEntry {
+	contextKeyMu.RLock()
+	defer contextKeyMu.RUnlock()
+	return contextKeyEntries
+}
+
+func getContextKeyEntry(key ContextKey) (*contextKeyEntry, bool) {
+	for _, e := range getContextKeyEntries() {
+		if e.key == key {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
+func (k *TypedContextKey[T]) Key() ContextKey {
+	return k.entry.key
+}
+
+func (k *TypedContextKey[T]) Name() string {
+	return k.entry.name
+}
+
+// Header returns http header which carries value
+func (k *TypedContextKey[T]) Header() string {
+	return k.entry.header
+}
+
+func (k *TypedContextKey[T]) Get(ctx context.Context) (T, bool) {
+	v, ok := ctx.Value(k.entry.key).(T)
+	return v, ok
+}
+
+func (k *TypedContextKey[T]) With(ctx context.Context, v T) context.Context {
+	return context.WithValue(ctx, k.entry.key, v)
+}
+
+// SetContextHeader sets values which are propagated over http into h
+func SetContextHeader(ctx context.Context, h http.Header) {
+	for _, e := range getContextKeyEntries() {
+		if e.propagation&PropagateHTTP == 0 {
+			continue
+		}
+		if s, ok := encodeContextValue(ctx, e); ok {
+			h.Set(e.header, s)
+		}
+	}
+}
+
+// ReadContextHeader returns a context with values which are propagated over http in h
+func ReadContextHeader(ctx context.Context, h http.Header) context.Context {
+	for _, e := range getContextKeyEntries() {
+		if e.propagation&PropagateHTTP != 0 {
+			ctx = decodeContextValue(ctx, e, h.Get(e.header))
+		}
+	}
+	return ctx
+}
+
+// ContextHeaderMiddleware reads context values from request header
+func ContextHeaderMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(ReadContextHeader(r.Context(), r.Header)))
+	})
+}
+
+func encodeContextValue(ctx context.Context, e *contextKeyEntry) (string, bool) {
+	v := ctx.Value(e.key)
+	if v == nil {
+		return "", false
+	}
+	s, err := e.encode(v)
+	if err != nil {
+		log.FromContext(ctx).Errorf("Encode context value %s: %v", e.name, err)
+		return "", false
+	}
+	return s, s != ""
+}
+
+func decodeContextValue(ctx context.Context, e *contextKeyEntry, s string) context.Context {
+	if s == "" {
+		return ctx
+	}
+	v, err := e.decode(s)
+	if err != nil {
+		log.FromContext(ctx).Errorf("Decode context value %s: %v", e.name, err)
+		return ctx
+	}
+	return context.WithValue(ctx, e.key, v)
+}
+
+func mustRegisterContextKey[T any](key ContextKey, name string, codec *ContextCodec[T], propagation ContextPropagation) *contextKeyEntry {
+	k, err := registerContextKey(key, name, codec, propagation)
+	if err != nil {
+		log.Panic(err)
+	}
+	return k.entry
+}
+
+func init() {
+	mustRegisterContextKey(CKDeviceID, "device_id", StringContextCodec, PropagateDetach|PropagateGRPC)
+	mustRegisterContextKey[string](CKRemoteAddr, "remote_addr", nil, PropagateDetach)
+	mustRegisterContextKey(CKLocation, "location", LocationContextCodec, PropagateDetach|PropagateGRPC)
+	mustRegisterContextKey(CKAccessToken, "access_token", StringContextCodec, PropagateDetach|PropagateGRPC)
+	mustRegisterContextKey(CKUserID, "user_id", Int64ContextCodec, PropagateDetach).isEmpty = func(v interface{}) bool {
+		id, _ := v.(int64)
+		return id <= 0
+	}
+	mustRegisterContextKey(CKTraceID, "trace_id", StringContextCodec, PropagateDetach)
+	mustRegisterContextKey[interface{}](CKUser, "user", nil, 0)
+	mustRegisterContextKey(CKLocale, "locale", StringContextCodec, PropagateDetach)
+}
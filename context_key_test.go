@@ -0,0 +1,179 @@
+package gox_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/gopub/gox"
+	"github.com/gopub/gox/geo"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type testFlags struct {
+	Beta bool `json:"beta"`
+}
+
+var (
+	ckTenantID = gox.MustRegisterContextKey("test_tenant_id", gox.Int64ContextCodec, gox.PropagateAll)
+	ckFlags    = gox.MustRegisterContextKey("test_flags", gox.JSONContextCodec[*testFlags](), gox.PropagateGRPC)
+	ckRequest  = gox.MustRegisterContextKey[string]("test_request", nil, 0)
+)
+
+func TestRegisterContextKey(t *testing.T) {
+	_, err := gox.RegisterContextKey("device_id", gox.StringContextCodec, gox.PropagateDetach)
+	require.Error(t, err)
+	_, err = gox.RegisterContextKey[string]("test_no_codec", nil, gox.PropagateGRPC)
+	require.Error(t, err)
+	_, err = gox.RegisterContextKey("Test_Upper", gox.StringContextCodec, gox.PropagateDetach)
+	require.Error(t, err)
+
+	require.Equal(t, "test_tenant_id", ckTenantID.Key().String())
+	require.Equal(t, "X-Test-Tenant-Id", ckTenantID.Header())
+	require.Equal(t, "trace_id", gox.CKTraceID.String())
+	require.Equal(t, "none", gox.ContextKey("unknown").String())
+}
+
+func TestDetachedContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	ctx = gox.WithTraceID(ctx, "trace1")
+	ctx = gox.WithUser(ctx, "user")
+	ctx = ckTenantID.With(ctx, 9)
+	ctx = ckFlags.With(ctx, &testFlags{Beta: true})
+	ctx = ckRequest.With(ctx, "req")
+
+	detached := gox.DetachedContext(ctx)
+	require.NoError(t, detached.Err())
+	require.Equal(t, "trace1", gox.GetTraceID(detached))
+	tenantID, ok := ckTenantID.Get(detached)
+	require.True(t, ok)
+	require.Equal(t, int64(9), tenantID)
+	require.Nil(t, gox.GetUser(detached))
+	_, ok = ckFlags.Get(detached)
+	require.False(t, ok)
+	_, ok = ckRequest.Get(detached)
+	require.False(t, ok)
+
+	ctx = context.WithValue(context.Background(), gox.CKDeviceID, "")
+	ctx = context.WithValue(ctx, gox.CKUserID, int64(-1))
+	ctx = context.WithValue(ctx, gox.CKLocation, (*geo.Point)(nil))
+	ctx = ckTenantID.With(ctx, 0)
+	detached = gox.DetachedContext(ctx)
+	require.Nil(t, detached.Value(gox.CKDeviceID))
+	require.Nil(t, detached.Value(gox.CKUserID))
+	require.Nil(t, detached.Value(gox.CKLocation))
+	_, ok = ckTenantID.Get(detached)
+	require.False(t, ok)
+}
+
+func TestLocationContextCodec(t *testing.T) {
+	s, err := gox.LocationContextCodec.Encode(nil)
+	require.NoError(t, err)
+	require.Empty(t, s)
+
+	s, err = gox.LocationContextCodec.Encode(&geo.Point{X: 121.5, Y: 31.25})
+	require.NoError(t, err)
+	p, err := gox.LocationContextCodec.Decode(s)
+	require.NoError(t, err)
+	require.Equal(t, &geo.Point{X: 121.5, Y: 31.25}, p)
+}
+
+func TestSetContextPropagation(t *testing.T) {
+	ctx := gox.WithTraceID(context.Background(), "trace1")
+	h := http.Header{}
+	gox.SetContextHeader(ctx, h)
+	require.Empty(t, h.Get(gox.TraceIDHeader))
+
+	require.Error(t, gox.SetContextPropagation(gox.CKUser, gox.PropagateHTTP))
+	require.Error(t, gox.SetContextPropagation(gox.ContextKey("unknown"), gox.PropagateHTTP))
+}
+
+func TestContextHeader(t *testing.T) {
+	require.NoError(t, gox.SetContextPropagation(gox.CKTraceID, gox.PropagateAll))
+	defer gox.SetContextPropagation(gox.CKTraceID, gox.PropagateDetach)
+
+	ctx := ckTenantID.With(gox.WithTraceID(context.Background(), "trace1"), 9)
+	ctx = gox.WithDeviceID(ctx, "device1")
+	h := http.Header{}
+	gox.SetContextHeader(ctx, h)
+	require.Equal(t, "9", h.Get("X-Test-Tenant-Id"))
+	require.Equal(t, "trace1", h.Get(gox.TraceIDHeader))
+	require.Empty(t, h.Get("X-Device-Id"))
+
+	var got context.Context
+	handler := gox.ContextHeaderMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Context()
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header = h
+	r.Header.Set("X-Device-Id", "device1")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	tenantID, _ := ckTenantID.Get(got)
+	require.Equal(t, int64(9), tenantID)
+	require.Equal(t, "trace1", gox.GetTraceID(got))
+	require.Empty(t, gox.GetDeviceID(got))
+
+	r.Header.Set("X-Test-Tenant-Id", "invalid")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+	_, ok := ckTenantID.Get(got)
+	require.False(t, ok)
+}
+
+func TestGRPCContextPropagation(t *testing.T) {
+	received := make(chan context.Context, 1)
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(gox.UnaryServerInterceptor))
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "gox.test.Context",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Get",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := new(wrappers.StringValue)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				return interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: "/gox.test.Context/Get"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+					received <- ctx
+					return req, nil
+				})
+			},
+		}},
+	}, struct{}{})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(),
+		grpc.WithUnaryInterceptor(gox.UnaryClientInterceptor),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.Dial()
+		}))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx := gox.WithDeviceID(context.Background(), "device1")
+	ctx = gox.WithLocation(ctx, &geo.Point{X: 121.5, Y: 31.25})
+	ctx = gox.WithUserID(ctx, 7)
+	ctx = ckTenantID.With(ctx, 9)
+	ctx = ckFlags.With(ctx, &testFlags{Beta: true})
+	ctx = gox.WithTraceID(ctx, "trace1")
+	ctx = gox.WithLocale(ctx, "zh")
+	require.NoError(t, conn.Invoke(ctx, "/gox.test.Context/Get", &wrappers.StringValue{Value: "hi"}, new(wrappers.StringValue)))
+
+	got := <-received
+	require.Equal(t, "device1", gox.GetDeviceID(got))
+	require.Equal(t, &geo.Point{X: 121.5, Y: 31.25}, gox.GetLocation(got))
+	require.Zero(t, gox.GetUserID(got))
+	require.Empty(t, gox.GetTraceID(got))
+	require.Empty(t, gox.GetLocale(got))
+	tenantID, _ := ckTenantID.Get(got)
+	require.Equal(t, int64(9), tenantID)
+	flags, _ := ckFlags.Get(got)
+	require.Equal(t, &testFlags{Beta: true}, flags)
+}
@@ -1,14 +1,36 @@
 package gox
 
-import "sync/atomic"
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gopub/gox/sql"
+	"github.com/gopub/log"
+)
 
 type Counter struct {
 	count int64
 }
 
+// Next increases the counter by 1 and returns the new value
 func (c *Counter) Next() int64 {
-	atomic.AddInt64(&c.count, 1)
-	return c.count
+	return atomic.AddInt64(&c.count, 1)
+}
+
+// Add increases the counter by delta and returns the new value
+func (c *Counter) Add(delta int64) int64 {
+	return atomic.AddInt64(&c.count, delta)
+}
+
+// Value returns the current value
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.count)
 }
 
 func (c *Counter) GetNumber() int64 {
@@ -20,3 +42,193 @@ var defaultCounter = &Counter{}
 func NextSequence() int64 {
 	return defaultCounter.Next()
 }
+
+// CounterStore persists counters
+type CounterStore interface {
+	// Reserve increases counter name by size and returns the new value, values in (value-size, value] are reserved
+	Reserve(name string, size int64) (int64, error)
+}
+
+// DurableCounter is a counter which survives restarts. It reserves values in blocks from CounterStore (hi/lo),
+// so values are never repeated, but unused values of a block are skipped after restart.
+// Set blockSize to 1 to minimize gaps, e.g. for invoice numbers.
+type DurableCounter struct {
+	store     CounterStore
+	name      string
+	blockSize int64
+
+	mu   sync.Mutex
+	next int64
+	max  int64
+}
+
+var _ NumberGetter = (*DurableCounter)(nil)
+
+func NewDurableCounter(store CounterStore, name string, blockSize int64) *DurableCounter {
+	if store == nil {
+		panic("store is nil")
+	}
+	if name == "" {
+		panic("name is empty")
+	}
+	if blockSize <= 0 {
+		panic("blockSize should be positive")
+	}
+	return &DurableCounter{
+		store:     store,
+		name:      name,
+		blockSize: blockSize,
+	}
+}
+
+// Next returns the next value, a new block is reserved if current block is used up
+func (c *DurableCounter) Next() (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.next == 0 || c.next > c.max {
+		max, err := c.store.Reserve(c.name, c.blockSize)
+		if err != nil {
+			return 0, fmt.Errorf("reserve %s: %w", c.name, err)
+		}
+		c.next = max - c.blockSize + 1
+		c.max = max
+	}
+	v := c.next
+	c.next++
+	return v, nil
+}
+
+// GetNumber returns the next value, it panics if no value can be reserved
+func (c *DurableCounter) GetNumber() int64 {
+	v, err := c.Next()
+	if err != nil {
+		log.Panic(err)
+	}
+	return v
+}
+
+// FileCounterStore stores each counter in a file named <name>.counter under dir.
+// Files are replaced atomically, so a crash never leaves a counter partially written.
+// It's safe for concurrent use within a process, but not across processes.
+type FileCounterStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+var _ CounterStore = (*FileCounterStore)(nil)
+
+func NewFileCounterStore(dir string) *FileCounterStore {
+	return &FileCounterStore{
+		dir: dir,
+	}
+}
+
+func (s *FileCounterStore) Reserve(name string, size int64) (int64, error) {
+	if strings.ContainsAny(name, `/\`) {
+		return 0, fmt.Errorf("invalid name %s", name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return 0, fmt.Errorf("make dir %s: %w", s.dir, err)
+	}
+
+	filename := filepath.Join(s.dir, name+".counter")
+	var v int64
+	b, err := ioutil.ReadFile(filename)
+	switch {
+	case err == nil:
+		v, err = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse %s: %w", filename, err)
+		}
+	case !os.IsNotExist(err):
+		return 0, fmt.Errorf("read %s: %w", filename, err)
+	}
+
+	v += size
+	if err = writeFileAtomically(filename, []byte(strconv.FormatInt(v, 10))); err != nil {
+		return 0, fmt.Errorf("write %s: %w", filename, err)
+	}
+	return v, nil
+}
+
+// writeFileAtomically writes data to a temporary file, syncs and renames it to filename,
+// then syncs the directory so that the rename survives a crash
+func writeFileAtomically(filename string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := f.Name()
+	if err = WriteAll(f, data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, filename)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return syncDir(filepath.Dir(filename))
+}
+
+func syncDir(name string) error {
+	d, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// SQLCounterStore stores counters in a table with columns: name and value.
+// Statements are written in PostgreSQL dialect.
+type SQLCounterStore struct {
+	db    sql.Executor
+	table string
+}
+
+var _ CounterStore = (*SQLCounterStore)(nil)
+
+func NewSQLCounterStore(db sql.Executor, table string) *SQLCounterStore {
+	if db == nil {
+		panic("db is nil")
+	}
+	if table == "" {
+		panic("table is empty")
+	}
+	return &SQLCounterStore{
+		db:    db,
+		table: table,
+	}
+}
+
+// CreateTable creates the counter table if it doesn't exist
+func (s *SQLCounterStore) CreateTable() error {
+	_, err := s.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+name VARCHAR(256) PRIMARY KEY,
+value BIGINT NOT NULL
+)`, s.table))
+	if err != nil {
+		return fmt.Errorf("create table %s: %w", s.table, err)
+	}
+	return nil
+}
+
+func (s *SQLCounterStore) Reserve(name string, size int64) (int64, error) {
+	var v int64
+	err := s.db.QueryRow(fmt.Sprintf(`INSERT INTO %s(name,value) VALUES($1,$2) ON CONFLICT (name) DO UPDATE
+SET value=%s.value+EXCLUDED.value RETURNING value`, s.table, s.table), name, size).Scan(&v)
+	if err != nil {
+		return 0, fmt.Errorf("reserve %s: %w", name, err)
+	}
+	return v, nil
+}
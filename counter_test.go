@@ -0,0 +1,57 @@
+package gox_test
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/gopub/gox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCounter(t *testing.T) {
+	c := &gox.Counter{}
+	const numWorkers = 8
+	const numValues = 1000
+	values := make(chan int64, numWorkers*numValues)
+	var wg sync.WaitGroup
+	for i := 0; i < numWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < numValues; j++ {
+				values <- c.Next()
+			}
+		}()
+	}
+	wg.Wait()
+	close(values)
+
+	seen := make(map[int64]bool, numWorkers*numValues)
+	for v := range values {
+		require.False(t, seen[v], "duplicate value %d", v)
+		seen[v] = true
+	}
+	require.Equal(t, int64(numWorkers*numValues), c.Value())
+}
+
+func TestDurableCounter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gox_counter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store := gox.NewFileCounterStore(dir)
+	c := gox.NewDurableCounter(store, "invoice", 10)
+	for i := int64(1); i <= 15; i++ {
+		v, err := c.Next()
+		require.NoError(t, err)
+		require.Equal(t, i, v)
+	}
+
+	// restarted counter continues from the next block
+	c = gox.NewDurableCounter(store, "invoice", 10)
+	v, err := c.Next()
+	require.NoError(t, err)
+	require.Equal(t, int64(21), v)
+}
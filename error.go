@@ -1,12 +1,13 @@
 package gox
 
 import (
-	"database/sql"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"runtime"
+	"time"
 
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"github.com/gopub/log"
 )
 
 type ErrorString string
@@ -19,24 +20,184 @@ const (
 	ErrNotExist ErrorString = "does not exist"
 )
 
+// CaptureErrorStack enables capturing stack trace while creating Error
+var CaptureErrorStack = false
+
 type Error struct {
 	Code    int    `json:"code"`
 	Message string `json:"message"`
+	// Reason is a machine-readable identifier, e.g. INVALID_TOKEN
+	Reason string `json:"reason,omitempty"`
+	// Details contains values like *ValidationError, *RetryInfo and *LocalizedMessage
+	Details []*Any   `json:"details,omitempty"`
+	Stack   []string `json:"stack,omitempty"`
+	// Key and Args are set by ErrorDef so that clients can localize message
+	Key  string `json:"key,omitempty"`
+	Args M      `json:"args,omitempty"`
+
+	cause      error
+	httpStatus int
 }
 
+var _ json.Marshaler = (*Error)(nil)
+var _ json.Unmarshaler = (*Error)(nil)
+
 func (e *Error) Error() string {
 	return e.Message
 }
 
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// Is reports whether target is *Error with the same code, and the same reason and key if they are not empty in target
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	return t.Code == e.Code && (t.Reason == "" || t.Reason == e.Reason) && (t.Key == "" || t.Key == e.Key)
+}
+
+func (e *Error) WithReason(reason string) *Error {
+	e.Reason = reason
+	return e
+}
+
+func (e *Error) WithCause(cause error) *Error {
+	e.cause = cause
+	return e
+}
+
+// WithDetails appends details which must be registered by RegisterAny
+func (e *Error) WithDetails(details ...interface{}) *Error {
+	for _, d := range details {
+		e.Details = append(e.Details, NewAny(d))
+	}
+	return e
+}
+
+func (e *Error) WithFieldViolations(violations ...*FieldViolation) *Error {
+	return e.WithDetails(&ValidationError{Violations: violations})
+}
+
+func (e *Error) WithRetryDelay(delay time.Duration) *Error {
+	return e.WithDetails(&RetryInfo{RetryDelay: delay})
+}
+
+func (e *Error) WithLocalizedMessage(locale, message string) *Error {
+	return e.WithDetails(&LocalizedMessage{Locale: locale, Message: message})
+}
+
+// WithStack captures stack trace of the caller
+func (e *Error) WithStack() *Error {
+	e.Stack = captureStack(3)
+	return e
+}
+
+// FieldViolations returns violations in all *ValidationError details
+func (e *Error) FieldViolations() []*FieldViolation {
+	var res []*FieldViolation
+	for _, d := range e.Details {
+		if v, ok := d.Val().(*ValidationError); ok {
+			res = append(res, v.Violations...)
+		}
+	}
+	return res
+}
+
+// RetryDelay returns delay in *RetryInfo detail
+func (e *Error) RetryDelay() (time.Duration, bool) {
+	for _, d := range e.Details {
+		if v, ok := d.Val().(*RetryInfo); ok {
+			return v.RetryDelay, true
+		}
+	}
+	return 0, false
+}
+
+// LocalizedMessage returns message in *LocalizedMessage detail of locale
+func (e *Error) LocalizedMessage(locale string) (string, bool) {
+	for _, d := range e.Details {
+		if v, ok := d.Val().(*LocalizedMessage); ok && v.Locale == locale {
+			return v.Message, true
+		}
+	}
+	return "", false
+}
+
+type errorJSON struct {
+	*jsonError
+	Cause string `json:"cause,omitempty"`
+}
+
+type jsonError Error
+
+func (e *Error) MarshalJSON() ([]byte, error) {
+	j := errorJSON{jsonError: (*jsonError)(e)}
+	if e.cause != nil {
+		j.Cause = e.cause.Error()
+	}
+	return json.Marshal(j)
+}
+
+func (e *Error) UnmarshalJSON(data []byte) error {
+	j := errorJSON{jsonError: (*jsonError)(e)}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if j.Cause != "" {
+		e.cause = ErrorString(j.Cause)
+	}
+	return nil
+}
+
+// RetryInfo tells clients when to retry
+type RetryInfo struct {
+	RetryDelay time.Duration `json:"retry_delay"`
+}
+
+// LocalizedMessage is an error message in locale, e.g. en-US
+type LocalizedMessage struct {
+	Locale  string `json:"locale"`
+	Message string `json:"message"`
+}
+
+func init() {
+	for _, prototype := range []interface{}{&Error{}, &ValidationError{}, &RetryInfo{}, &LocalizedMessage{}} {
+		if err := RegisterAny(prototype); err != nil {
+			log.Panic(err)
+		}
+	}
+}
+
+func captureStack(skip int) []string {
+	pc := make([]uintptr, 32)
+	n := runtime.Callers(skip, pc)
+	frames := runtime.CallersFrames(pc[:n])
+	var stack []string
+	for {
+		f, more := frames.Next()
+		stack = append(stack, fmt.Sprintf("%s (%s:%d)", f.Function, f.File, f.Line))
+		if !more {
+			return stack
+		}
+	}
+}
+
 func NewError(code int, msgFormat string, args ...interface{}) *Error {
 	message := fmt.Sprintf(msgFormat, args...)
 	if len(message) == 0 {
 		message = http.StatusText(code % 1000)
 	}
-	return &Error{
+	e := &Error{
 		Code:    code,
 		Message: message,
 	}
+	if CaptureErrorStack {
+		e.Stack = captureStack(3)
+	}
+	return e
 }
 
 func InternalError(format string, args ...interface{}) *Error {
@@ -63,55 +224,17 @@ func Conflict(format string, args ...interface{}) *Error {
 	return NewError(http.StatusConflict, format, args...)
 }
 
-func ToStatusError(err error) error {
-	if err == nil {
-		return nil
-	}
-
-	err = Cause(err)
-
-	// if err is status error, return directly
-	_, ok := status.FromError(err)
-	if ok {
-		return err
-	}
-
-	if err == ErrNotExist || err == sql.ErrNoRows {
-		return status.Error(codes.Code(http.StatusNotFound), err.Error())
-	}
-
-	switch v := err.(type) {
-	case *Error:
-		return status.Error(codes.Code(v.Code), v.Error())
-	default:
-		return status.Error(codes.Code(http.StatusInternalServerError), err.Error())
-	}
-}
-
-func FromStatusError(err error) error {
-	if err == nil {
-		return nil
-	}
-
-	s, ok := status.FromError(err)
-	if !ok {
-		return err
-	}
-
-	if int(s.Code()) == http.StatusNotFound &&
-		(s.Message() == ErrNotExist.Error() || s.Message() == sql.ErrNoRows.Error()) {
-		return ErrNotExist
-	}
-
-	return NewError(int(s.Code()), s.Message())
-}
-
+// Cause returns the innermost error in err's Unwrap chain
 func Cause(err error) error {
 	for {
-		if e, ok := err.(interface{ Unwrap() error }); ok {
-			err = e.Unwrap()
-		} else {
+		e, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return err
+		}
+		next := e.Unwrap()
+		if next == nil {
 			return err
 		}
+		err = next
 	}
 }
@@ -0,0 +1,278 @@
+package gox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrorDef defines an error with stable code and key, http status and message templates per locale.
+// Templates refer to args by name, e.g. "user {name} not found"
+type ErrorDef struct {
+	Key        string            `json:"key"`
+	Code       int               `json:"code"`
+	HTTPStatus int               `json:"http_status,omitempty"`
+	Messages   map[string]string `json:"messages"`
+
+	catalog *ErrorCatalog
+}
+
+// New creates an error with args. Message is rendered in default locale of the catalog which d is registered with
+func (d *ErrorDef) New(args M) *Error {
+	e := &Error{
+		Code:       d.Code,
+		Key:        d.Key,
+		Args:       args,
+		httpStatus: d.HTTPStatus,
+	}
+	locale := ""
+	if d.catalog != nil {
+		locale = d.catalog.defaultLocale
+	}
+	e.Message = d.Render(args, locale)
+	if CaptureErrorStack {
+		e.Stack = captureStack(3)
+	}
+	return e
+}
+
+// Is reports whether err is created by d
+func (d *ErrorDef) Is(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.Key == d.Key
+}
+
+// Render renders message template of the best matched locale with args.
+// E.g. locale zh-Hans-CN falls back to zh-Hans, zh, then any message
+func (d *ErrorDef) Render(args M, locale string) string {
+	tmpl, ok := d.template(locale)
+	if !ok {
+		if d.catalog != nil {
+			tmpl, ok = d.template(d.catalog.defaultLocale)
+		}
+		if !ok {
+			return http.StatusText(d.HTTPStatus)
+		}
+	}
+	return renderErrorTemplate(tmpl, args)
+}
+
+func (d *ErrorDef) template(locale string) (string, bool) {
+	for locale != "" {
+		if tmpl, ok := d.Messages[locale]; ok {
+			return tmpl, true
+		}
+		i := strings.LastIndexAny(locale, "-_")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return "", false
+}
+
+var errorTemplateArgRegexp = regexp.MustCompile(`\{([A-Za-z0-9_.]+)\}`)
+
+func renderErrorTemplate(tmpl string, args M) string {
+	return errorTemplateArgRegexp.ReplaceAllStringFunc(tmpl, func(s string) string {
+		v, ok := args.Get(s[1 : len(s)-1])
+		if !ok {
+			return s
+		}
+		return fmt.Sprint(v)
+	})
+}
+
+// ErrorCatalog is a registry of ErrorDef indexed by both key and code
+type ErrorCatalog struct {
+	mu            sync.RWMutex
+	defaultLocale string
+	keyToDef      map[string]*ErrorDef
+	codeToDef     map[int]*ErrorDef
+}
+
+// DefaultErrorCatalog is used by WriteError to localize errors
+var DefaultErrorCatalog = NewErrorCatalog("en")
+
+func NewErrorCatalog(defaultLocale string) *ErrorCatalog {
+	return &ErrorCatalog{
+		defaultLocale: defaultLocale,
+		keyToDef:      make(map[string]*ErrorDef),
+		codeToDef:     make(map[int]*ErrorDef),
+	}
+}
+
+func (c *ErrorCatalog) DefaultLocale() string {
+	return c.defaultLocale
+}
+
+// Register adds def into c. Key and code must be unique in c
+func (c *ErrorCatalog) Register(def *ErrorDef) error {
+	if def == nil {
+		return errors.New("def is nil")
+	}
+	if def.Key == "" {
+		return errors.New("key is empty")
+	}
+	if def.Code <= 0 {
+		return fmt.Errorf("invalid code %d of %s", def.Code, def.Key)
+	}
+	if def.HTTPStatus == 0 {
+		def.HTTPStatus = (&Error{Code: def.Code}).HTTPStatus()
+	} else if http.StatusText(def.HTTPStatus) == "" {
+		return fmt.Errorf("invalid http status %d of %s", def.HTTPStatus, def.Key)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.keyToDef[def.Key]; ok {
+		return fmt.Errorf("duplicate key %s", def.Key)
+	}
+	if d, ok := c.codeToDef[def.Code]; ok {
+		return fmt.Errorf("duplicate code %d of %s and %s", def.Code, d.Key, def.Key)
+	}
+	def.catalog = c
+	c.keyToDef[def.Key] = def
+	c.codeToDef[def.Code] = def
+	return nil
+}
+
+// MustRegister is like Register but panics if def cannot be registered. It simplifies declaring errors, e.g.
+//
+//	var ErrUserNotFound = gox.DefaultErrorCatalog.MustRegister(&gox.ErrorDef{
+//		Key:      "user_not_found",
+//		Code:     1404,
+//		Messages: map[string]string{"en": "User {id} not found"},
+//	})
+func (c *ErrorCatalog) MustRegister(def *ErrorDef) *ErrorDef {
+	if err := c.Register(def); err != nil {
+		panic(err)
+	}
+	return def
+}
+
+func (c *ErrorCatalog) Get(key string) (*ErrorDef, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	d, ok := c.keyToDef[key]
+	return d, ok
+}
+
+func (c *ErrorCatalog) GetByCode(code int) (*ErrorDef, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	d, ok := c.codeToDef[code]
+	return d, ok
+}
+
+// Localize returns a copy of e whose message is rendered in the first supported locale of locales.
+// e is returned as is if it's not defined in c
+func (c *ErrorCatalog) Localize(e *Error, locales ...string) *Error {
+	if e == nil || e.Key == "" {
+		return e
+	}
+	d, ok := c.Get(e.Key)
+	if !ok {
+		return e
+	}
+	locale := c.defaultLocale
+	for _, l := range locales {
+		if _, ok := d.template(l); ok {
+			locale = l
+			break
+		}
+	}
+	le := *e
+	le.Message = d.Render(e.Args, locale)
+	if le.httpStatus == 0 {
+		le.httpStatus = d.HTTPStatus
+	}
+	return &le
+}
+
+// Defs returns all definitions ordered by code
+func (c *ErrorCatalog) Defs() []*ErrorDef {
+	c.mu.RLock()
+	defs := make([]*ErrorDef, 0, len(c.codeToDef))
+	for _, d := range c.codeToDef {
+		defs = append(defs, d)
+	}
+	c.mu.RUnlock()
+	sort.Slice(defs, func(i, j int) bool {
+		return defs[i].Code < defs[j].Code
+	})
+	return defs
+}
+
+type errorCatalogJSON struct {
+	DefaultLocale string      `json:"default_locale"`
+	Errors        []*ErrorDef `json:"errors"`
+}
+
+// MarshalJSON exports c, which can be loaded by ParseErrorCatalog, e.g. in mobile apps
+func (c *ErrorCatalog) MarshalJSON() ([]byte, error) {
+	return json.Marshal(&errorCatalogJSON{
+		DefaultLocale: c.defaultLocale,
+		Errors:        c.Defs(),
+	})
+}
+
+// ParseErrorCatalog loads catalog exported by ErrorCatalog.MarshalJSON
+func ParseErrorCatalog(data []byte) (*ErrorCatalog, error) {
+	var j errorCatalogJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	c := NewErrorCatalog(j.DefaultLocale)
+	for _, d := range j.Errors {
+		if err := c.Register(d); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// ParseAcceptLanguage returns languages in Accept-Language header ordered by quality, e.g. "zh-CN,zh;q=0.9,en;q=0.8"
+func ParseAcceptLanguage(header string) []string {
+	type lang struct {
+		tag string
+		q   float64
+	}
+	var langs []lang
+	for _, s := range strings.Split(header, ",") {
+		l := lang{q: 1}
+		tag, params, _ := strings.Cut(strings.TrimSpace(s), ";")
+		l.tag = strings.TrimSpace(tag)
+		if l.tag == "" || l.tag == "*" {
+			continue
+		}
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			f, err := strconv.ParseFloat(q, 64)
+			if err != nil || f <= 0 {
+				continue
+			}
+			l.q = f
+		}
+		langs = append(langs, l)
+	}
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+	return Map(langs, func(l lang) string {
+		return l.tag
+	})
+}
+
+// RequestLocales returns locale in r's context followed by languages in Accept-Language header
+func RequestLocales(r *http.Request) []string {
+	locales := ParseAcceptLanguage(r.Header.Get("Accept-Language"))
+	if locale := GetLocale(r.Context()); locale != "" {
+		locales = append([]string{locale}, locales...)
+	}
+	return locales
+}
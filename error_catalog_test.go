@@ -0,0 +1,122 @@
+package gox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gopub/gox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCatalog(t *testing.T) {
+	c := gox.NewErrorCatalog("en")
+	userNotFound := c.MustRegister(&gox.ErrorDef{
+		Key:  "user_not_found",
+		Code: 1404,
+		Messages: map[string]string{
+			"en":      "User {id} not found",
+			"zh":      "用户{id}不存在",
+			"zh-Hant": "用戶{id}不存在",
+		},
+	})
+	quotaExceeded := c.MustRegister(&gox.ErrorDef{
+		Key:        "quota_exceeded",
+		Code:       1001,
+		HTTPStatus: http.StatusTooManyRequests,
+		Messages:   map[string]string{"en": "Quota {quota.name} exceeded"},
+	})
+
+	t.Run("Register", func(t *testing.T) {
+		require.Error(t, c.Register(&gox.ErrorDef{Key: "user_not_found", Code: 1405}))
+		require.Error(t, c.Register(&gox.ErrorDef{Key: "other", Code: 1404}))
+		require.Error(t, c.Register(&gox.ErrorDef{Key: "", Code: 1406}))
+		require.Error(t, c.Register(&gox.ErrorDef{Key: "bad_status", Code: 1407, HTTPStatus: 999}))
+		d, ok := c.GetByCode(1404)
+		require.True(t, ok)
+		require.Equal(t, userNotFound, d)
+	})
+
+	t.Run("New", func(t *testing.T) {
+		err := fmt.Errorf("get user: %w", userNotFound.New(gox.M{"id": 10}))
+		var e *gox.Error
+		require.True(t, errors.As(err, &e))
+		require.Equal(t, "User 10 not found", e.Message)
+		require.Equal(t, "user_not_found", e.Key)
+		require.Equal(t, http.StatusNotFound, e.HTTPStatus())
+		require.True(t, userNotFound.Is(err))
+		require.False(t, quotaExceeded.Is(err))
+
+		e = quotaExceeded.New(gox.M{"quota": gox.M{"name": "storage"}})
+		require.Equal(t, "Quota storage exceeded", e.Message)
+		require.Equal(t, http.StatusTooManyRequests, e.HTTPStatus())
+		require.Equal(t, "Quota {quota.name} exceeded", quotaExceeded.New(nil).Message)
+	})
+
+	t.Run("Localize", func(t *testing.T) {
+		e := userNotFound.New(gox.M{"id": 10})
+		require.Equal(t, "用户10不存在", c.Localize(e, "zh-CN").Message)
+		require.Equal(t, "用戶10不存在", c.Localize(e, "zh-Hant-TW").Message)
+		require.Equal(t, "用户10不存在", c.Localize(e, "fr", "zh").Message)
+		require.Equal(t, "User 10 not found", c.Localize(e, "fr").Message)
+		require.Equal(t, "User 10 not found", e.Message)
+
+		other := gox.NotFound("not found")
+		require.Equal(t, other, c.Localize(other, "zh"))
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		data, err := json.Marshal(c)
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+			"default_locale": "en",
+			"errors": [
+				{"key": "quota_exceeded", "code": 1001, "http_status": 429, "messages": {"en": "Quota {quota.name} exceeded"}},
+				{"key": "user_not_found", "code": 1404, "http_status": 404, "messages": {"en": "User {id} not found", "zh": "用户{id}不存在", "zh-Hant": "用戶{id}不存在"}}
+			]
+		}`, string(data))
+
+		c2, err := gox.ParseErrorCatalog(data)
+		require.NoError(t, err)
+		d, ok := c2.Get("user_not_found")
+		require.True(t, ok)
+		require.Equal(t, "用户1不存在", d.Render(gox.M{"id": 1}, "zh"))
+	})
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	require.Equal(t, []string{"zh-CN", "zh", "en"}, gox.ParseAcceptLanguage("en;q=0.8, zh-CN,zh;q=0.9, *;q=0.5, fr;q=0"))
+	require.Empty(t, gox.ParseAcceptLanguage(""))
+}
+
+func TestWriteError_Localized(t *testing.T) {
+	def := gox.DefaultErrorCatalog.MustRegister(&gox.ErrorDef{
+		Key:      "test_order_not_found",
+		Code:     91404,
+		Messages: map[string]string{"en": "Order {id} not found", "zh": "订单{id}不存在"},
+	})
+	handler := gox.ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		return def.New(gox.M{"id": "A1"})
+	})
+
+	r := httptest.NewRequest(http.MethodGet, "/orders/A1", nil)
+	r.Header.Set("Accept-Language", "zh-CN,en;q=0.5")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.Equal(t, http.StatusNotFound, w.Code)
+	var e *gox.Error
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &e))
+	require.Equal(t, "订单A1不存在", e.Message)
+	require.Equal(t, "test_order_not_found", e.Key)
+	require.Equal(t, "A1", e.Args["id"])
+
+	r = r.WithContext(gox.WithLocale(context.Background(), "en"))
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &e))
+	require.Equal(t, "Order A1 not found", e.Message)
+}
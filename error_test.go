@@ -0,0 +1,80 @@
+package gox_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gopub/gox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestError(t *testing.T) {
+	t.Run("Cause", func(t *testing.T) {
+		err := gox.NotFound("user not found").WithReason("USER_NOT_FOUND").WithCause(sql.ErrNoRows)
+		wrapped := fmt.Errorf("get user: %w", err)
+		require.True(t, errors.Is(wrapped, sql.ErrNoRows))
+		require.True(t, errors.Is(wrapped, gox.NotFound("")))
+		require.True(t, errors.Is(wrapped, gox.NotFound("").WithReason("USER_NOT_FOUND")))
+		require.False(t, errors.Is(wrapped, gox.NotFound("").WithReason("OTHER")))
+		require.False(t, errors.Is(wrapped, gox.BadRequest("")))
+
+		var e *gox.Error
+		require.True(t, errors.As(wrapped, &e))
+		require.Equal(t, http.StatusNotFound, e.Code)
+
+		require.Equal(t, sql.ErrNoRows, gox.Cause(wrapped))
+		noCause := gox.NotFound("user not found")
+		require.Equal(t, noCause, gox.Cause(noCause))
+		require.Equal(t, noCause, gox.Cause(fmt.Errorf("get user: %w", noCause)))
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		err := gox.BadRequest("invalid input").
+			WithReason("INVALID_INPUT").
+			WithCause(errors.New("bad email")).
+			WithFieldViolations(&gox.FieldViolation{Field: "email", Description: "must be valid email"}).
+			WithRetryDelay(2*time.Second).
+			WithLocalizedMessage("zh-CN", "输入无效")
+		data, jerr := json.Marshal(err)
+		require.NoError(t, jerr)
+		require.JSONEq(t, `{
+			"code": 400,
+			"message": "invalid input",
+			"reason": "INVALID_INPUT",
+			"cause": "bad email",
+			"details": [
+				{"@t": "validation_error", "violations": [{"field": "email", "description": "must be valid email"}]},
+				{"@t": "retry_info", "retry_delay": 2000000000},
+				{"@t": "localized_message", "locale": "zh-CN", "message": "输入无效"}
+			]
+		}`, string(data))
+
+		var decoded *gox.Error
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		require.Equal(t, "INVALID_INPUT", decoded.Reason)
+		require.EqualError(t, errors.Unwrap(decoded), "bad email")
+		require.Equal(t, []*gox.FieldViolation{{Field: "email", Description: "must be valid email"}}, decoded.FieldViolations())
+		delay, ok := decoded.RetryDelay()
+		require.True(t, ok)
+		require.Equal(t, 2*time.Second, delay)
+		msg, ok := decoded.LocalizedMessage("zh-CN")
+		require.True(t, ok)
+		require.Equal(t, "输入无效", msg)
+	})
+
+	t.Run("Stack", func(t *testing.T) {
+		require.Empty(t, gox.InternalError("").Stack)
+		e := gox.InternalError("").WithStack()
+		require.NotEmpty(t, e.Stack)
+		require.Contains(t, e.Stack[0], "TestError")
+
+		gox.CaptureErrorStack = true
+		defer func() { gox.CaptureErrorStack = false }()
+		require.NotEmpty(t, gox.NewError(500, "").Stack)
+	})
+}
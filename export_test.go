@@ -0,0 +1,15 @@
+package gox
+
+import "time"
+
+func (l *ShardLease) SetClock(now func() time.Time) {
+	l.now = now
+}
+
+func (c *MemoryShardCoordinator) SetClock(now func() time.Time) {
+	c.now = now
+}
+
+func (l *ShardLease) Renew() {
+	l.renew()
+}
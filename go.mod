@@ -1,24 +1,37 @@
 module github.com/gopub/gox
 
-go 1.13
+go 1.23
 
 require (
 	github.com/golang/geo v0.0.0-20190916061304-5b978397cfec
+	github.com/golang/protobuf v1.3.2
 	github.com/google/go-cmp v0.2.0
 	github.com/gopub/log v1.0.6
 	github.com/nyaruka/phonenumbers v1.0.53
-	github.com/pelletier/go-toml v1.6.0 // indirect
 	github.com/shopspring/decimal v0.0.0-20191130220710-360f2bc03045
+	github.com/spf13/viper v1.6.1
+	github.com/stretchr/testify v1.3.0
+	golang.org/x/net v0.0.0-20191207000613-e7e4b65ae663
+	google.golang.org/genproto v0.0.0-20191206224255-0243a4be9c8f
+	google.golang.org/grpc v1.25.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/fsnotify/fsnotify v1.4.7 // indirect
+	github.com/hashicorp/hcl v1.0.0 // indirect
+	github.com/magiconair/properties v1.8.1 // indirect
+	github.com/mitchellh/mapstructure v1.1.2 // indirect
+	github.com/pelletier/go-toml v1.6.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/spf13/afero v1.2.2 // indirect
+	github.com/spf13/cast v1.3.0 // indirect
 	github.com/spf13/jwalterweatherman v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
-	github.com/spf13/viper v1.6.1
-	github.com/stretchr/testify v1.3.0
-	golang.org/x/net v0.0.0-20191207000613-e7e4b65ae663 // indirect
+	github.com/subosito/gotenv v1.2.0 // indirect
 	golang.org/x/sys v0.0.0-20191206220618-eeba5f6aabab // indirect
 	golang.org/x/text v0.3.2 // indirect
 	golang.org/x/tools v0.0.0-20200220051852-2086a0a691c0 // indirect
-	google.golang.org/genproto v0.0.0-20191206224255-0243a4be9c8f // indirect
-	google.golang.org/grpc v1.25.1
+	gopkg.in/ini.v1 v1.51.0 // indirect
 	gopkg.in/yaml.v2 v2.2.7 // indirect
 )
@@ -2,11 +2,8 @@ package gox
 
 import (
 	"context"
-	"fmt"
 	"time"
 
-	"github.com/gopub/gox/geo"
-
 	"github.com/gopub/log"
 
 	"google.golang.org/grpc"
@@ -44,17 +41,13 @@ func StreamClientInterceptor(ctx context.Context,
 
 func outgoingContext(ctx context.Context) context.Context {
 	md := metadata.New(nil)
-
-	if deviceID := GetDeviceID(ctx); len(deviceID) > 0 {
-		md.Set(CKDeviceID.String(), deviceID)
-	}
-
-	if coordinate := GetLocation(ctx); coordinate != nil {
-		md.Set(CKLocation.String(), fmt.Sprintf("%f,%f", coordinate.Y, coordinate.X))
-	}
-
-	if token := GetAccessToken(ctx); len(token) > 0 {
-		md.Set(CKAccessToken.String(), token)
+	for _, e := range getContextKeyEntries() {
+		if e.propagation&PropagateGRPC == 0 {
+			continue
+		}
+		if v, ok := encodeContextValue(ctx, e); ok {
+			md.Set(e.name, v)
+		}
 	}
 
 	if ClientMetadataFromContext != nil {
@@ -100,29 +93,17 @@ func handleIncomingMetadata(ctx context.Context) context.Context {
 		return ctx
 	}
 
-	coordinateVal := md.Get(CKLocation.String())
-	if len(coordinateVal) > 0 && len(coordinateVal[0]) > 0 {
-		var lat, lng float64
-		if _, err := fmt.Sscanf(coordinateVal[0], "%f,%f", &lat, &lng); err == nil {
-			coordinate := &geo.Point{
-				X: lng,
-				Y: lat,
-			}
-			ctx = WithLocation(ctx, coordinate)
+	for _, e := range getContextKeyEntries() {
+		if e.propagation&PropagateGRPC == 0 {
+			continue
+		}
+		if v := md.Get(e.name); len(v) > 0 {
+			ctx = decodeContextValue(ctx, e, v[0])
 		}
 	}
 
-	deviceID := md.Get(CKDeviceID.String())
-	if len(deviceID) > 0 && len(deviceID[0]) > 0 {
-		ctx = WithDeviceID(ctx, deviceID[0])
-	}
-
-	accessToken := md.Get(CKAccessToken.String())
-	if len(accessToken) > 0 && len(accessToken[0]) > 0 {
-		ctx = WithAccessToken(ctx, accessToken[0])
-		if TokenAuthenticator != nil {
-			ctx = TokenAuthenticator(ctx, accessToken[0])
-		}
+	if token := GetAccessToken(ctx); len(token) > 0 && TokenAuthenticator != nil {
+		ctx = TokenAuthenticator(ctx, token)
 	}
 
 	if WithMetadata != nil {
@@ -0,0 +1,220 @@
+package gox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gopub/log"
+)
+
+const (
+	MIMEJSON        = "application/json"
+	MIMEProblemJSON = "application/problem+json"
+
+	// TraceIDHeader carries trace id in error responses
+	TraceIDHeader = "X-Trace-Id"
+)
+
+// HTTPStatus returns http status of e. It's defined by ErrorDef if e is created by it,
+// otherwise application codes larger than 999 are mapped by code % 1000
+func (e *Error) HTTPStatus() int {
+	if e.httpStatus != 0 {
+		return e.httpStatus
+	}
+	if d, ok := DefaultErrorCatalog.Get(e.Key); ok && e.Key != "" {
+		return d.HTTPStatus
+	}
+	code := e.Code
+	if code >= 1000 {
+		code %= 1000
+	}
+	if code < 100 || code > 599 {
+		return http.StatusInternalServerError
+	}
+	return code
+}
+
+type httpError struct {
+	*Error
+	TraceID string `json:"trace_id,omitempty"`
+}
+
+func (e *httpError) MarshalJSON() ([]byte, error) {
+	data, err := e.Error.MarshalJSON()
+	if err != nil || e.TraceID == "" {
+		return data, err
+	}
+	var m map[string]json.RawMessage
+	if err = json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	m["trace_id"], _ = json.Marshal(e.TraceID)
+	return json.Marshal(m)
+}
+
+// problem is defined by RFC 7807
+type problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	Code     int    `json:"code"`
+	Reason   string `json:"reason,omitempty"`
+	Details  []*Any `json:"details,omitempty"`
+	TraceID  string `json:"trace_id,omitempty"`
+}
+
+// WriteError classifies err by ToError and writes it as JSON, or problem details defined by RFC 7807
+// if r accepts application/problem+json. Trace id in r's context is attached.
+// Cause and stack trace are logged rather than written to clients. So are messages of server errors,
+// e.g. database errors, unless they are defined in DefaultErrorCatalog
+func WriteError(w http.ResponseWriter, r *http.Request, err error) {
+	e := ToError(err)
+	if e == nil {
+		e = InternalError("")
+	}
+	e = DefaultErrorCatalog.Localize(e, RequestLocales(r)...)
+	httpStatus := e.HTTPStatus()
+	traceID := GetTraceID(r.Context())
+	if traceID != "" {
+		w.Header().Set(TraceIDHeader, traceID)
+	}
+	logger := log.FromContext(r.Context())
+	if httpStatus >= 500 {
+		logger.Errorf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	hideMessage := httpStatus >= 500 && e.Key == ""
+	if e.cause != nil || len(e.Stack) > 0 || hideMessage {
+		if e.cause != nil || len(e.Stack) > 0 {
+			logger.Warnf("%s %s: cause=%v\n%s", r.Method, r.URL.Path, e.cause, strings.Join(e.Stack, "\n"))
+		}
+		pe := *e
+		pe.cause = nil
+		pe.Stack = nil
+		if hideMessage {
+			pe.Message = http.StatusText(httpStatus)
+		}
+		e = &pe
+	}
+
+	var body interface{}
+	if acceptsProblemJSON(r) {
+		w.Header().Set("Content-Type", MIMEProblemJSON)
+		body = &problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(httpStatus),
+			Status:   httpStatus,
+			Detail:   e.Message,
+			Instance: r.URL.Path,
+			Code:     e.Code,
+			Reason:   e.Reason,
+			Details:  e.Details,
+			TraceID:  traceID,
+		}
+	} else {
+		w.Header().Set("Content-Type", MIMEJSON+"; charset=utf-8")
+		body = &httpError{Error: e, TraceID: traceID}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		log.Errorf("Marshal error: %v", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(httpStatus)
+	if _, err = w.Write(data); err != nil {
+		log.Errorf("Write error: %v", err)
+	}
+}
+
+func acceptsProblemJSON(r *http.Request) bool {
+	for _, s := range strings.Split(r.Header.Get("Accept"), ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(s))
+		if err == nil && mt == MIMEProblemJSON && params["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrorHandlerFunc is an http handler which returns error
+type ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+func (f ErrorHandlerFunc) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := f(w, r); err != nil {
+		WriteError(w, r, err)
+	}
+}
+
+// recoveryWriter tracks whether header has been written
+type recoveryWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *recoveryWriter) WriteHeader(statusCode int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *recoveryWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recoveryWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Hijack lets websocket handlers take over the connection
+func (w *recoveryWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		w.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the original writer, which is used by http.ResponseController
+func (w *recoveryWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// ErrorMiddleware recovers panics in next and writes them as internal errors
+func ErrorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoveryWriter{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			e := InternalError("").WithStack()
+			log.FromContext(r.Context()).Errorf("Panic: %v\n%s", p, strings.Join(e.Stack, "\n"))
+			if rw.wroteHeader {
+				return
+			}
+			// panic value and stack are logged rather than exposed
+			e.Stack = nil
+			WriteError(rw, r, e)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
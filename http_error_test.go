@@ -0,0 +1,149 @@
+package gox_test
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gopub/gox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteError(t *testing.T) {
+	handler := gox.ErrorMiddleware(gox.ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		switch r.URL.Path {
+		case "/not_found":
+			return fmt.Errorf("get user: %w", sql.ErrNoRows)
+		case "/bad":
+			return gox.NewError(1400, "invalid name").WithReason("INVALID_NAME").
+				WithFieldViolations(&gox.FieldViolation{Field: "name", Description: "empty"})
+		case "/cause":
+			return gox.NotFound("user not found").WithCause(errors.New("select from users: secret")).WithStack()
+		case "/internal":
+			return errors.New("db down")
+		case "/unavailable":
+			return gox.NewError(http.StatusServiceUnavailable, "dial tcp 10.0.0.1:5432: connection refused")
+		case "/panic":
+			panic("boom")
+		default:
+			w.WriteHeader(http.StatusNoContent)
+			return nil
+		}
+	}))
+
+	serve := func(path, accept string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		r = r.WithContext(gox.WithTraceID(r.Context(), "trace-1"))
+		if accept != "" {
+			r.Header.Set("Accept", accept)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		var body map[string]interface{}
+		if w.Body.Len() > 0 {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		}
+		return w, body
+	}
+
+	t.Run("NotFound", func(t *testing.T) {
+		w, body := serve("/not_found", "")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+		require.Equal(t, "trace-1", w.Header().Get(gox.TraceIDHeader))
+		require.Equal(t, gox.ErrNotExist.Error(), body["message"])
+		require.Equal(t, "trace-1", body["trace_id"])
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		w, body := serve("/bad", "application/json")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Equal(t, float64(1400), body["code"])
+		require.Equal(t, "INVALID_NAME", body["reason"])
+		require.Len(t, body["details"], 1)
+	})
+
+	t.Run("Problem", func(t *testing.T) {
+		w, body := serve("/bad", "application/problem+json, application/json;q=0.9")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Equal(t, gox.MIMEProblemJSON, w.Header().Get("Content-Type"))
+		require.Equal(t, "about:blank", body["type"])
+		require.Equal(t, "Bad Request", body["title"])
+		require.Equal(t, float64(400), body["status"])
+		require.Equal(t, "invalid name", body["detail"])
+		require.Equal(t, "/bad", body["instance"])
+		require.Equal(t, "trace-1", body["trace_id"])
+	})
+
+	t.Run("HideCause", func(t *testing.T) {
+		for _, accept := range []string{"", gox.MIMEProblemJSON} {
+			w, body := serve("/cause", accept)
+			require.Equal(t, http.StatusNotFound, w.Code)
+			require.NotContains(t, body, "cause")
+			require.NotContains(t, body, "stack")
+			require.NotContains(t, w.Body.String(), "secret")
+		}
+	})
+
+	t.Run("Internal", func(t *testing.T) {
+		w, body := serve("/internal", "")
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Equal(t, http.StatusText(http.StatusInternalServerError), body["message"])
+		require.NotContains(t, w.Body.String(), "db down")
+
+		w, body = serve("/internal", gox.MIMEProblemJSON)
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Equal(t, http.StatusText(http.StatusInternalServerError), body["detail"])
+		require.NotContains(t, w.Body.String(), "db down")
+
+		w, body = serve("/unavailable", "")
+		require.Equal(t, http.StatusServiceUnavailable, w.Code)
+		require.Equal(t, http.StatusText(http.StatusServiceUnavailable), body["message"])
+	})
+
+	t.Run("Panic", func(t *testing.T) {
+		w, body := serve("/panic", "")
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Equal(t, http.StatusText(http.StatusInternalServerError), body["message"])
+		require.Nil(t, body["stack"])
+		require.Equal(t, "trace-1", body["trace_id"])
+	})
+
+	t.Run("OK", func(t *testing.T) {
+		w, _ := serve("/ok", "")
+		require.Equal(t, http.StatusNoContent, w.Code)
+	})
+}
+
+func TestErrorMiddleware_Hijack(t *testing.T) {
+	handler := gox.ErrorMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		require.NoError(t, rc.SetWriteDeadline(time.Now().Add(time.Second)))
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 2\r\nConnection: close\r\n\r\nhi")
+		require.NoError(t, err)
+		require.NoError(t, rw.Flush())
+	}))
+	s := httptest.NewServer(handler)
+	defer s.Close()
+	resp, err := http.Get(s.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hi", string(body))
+
+	w := httptest.NewRecorder()
+	gox.ErrorMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _, err := w.(http.Hijacker).Hijack()
+		require.Error(t, err)
+	})).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -5,7 +5,9 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"math"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gopub/log"
@@ -31,16 +33,22 @@ type ID int64
 const DefaultShardBitSize = 3 // 最多8个shard
 const DefaultSeqBitSize = 6   // 每个shard每ms不能超过64次调用
 
-var epoch time.Time
+var epoch = time.Date(2019, time.January, 2, 15, 4, 5, 0, time.UTC)
 var defaultIDGenerator IDGenerator
 var slowIDGenerator IDGenerator
 var fastIDGenerator IDGenerator
 
+// defaultShardIDGetter resolves shard id with DefaultShardIDResolver
+var defaultShardIDGetter = &resolverNumberGetter{
+	ShardIDResolverFunc(func() (int64, error) {
+		return DefaultShardIDResolver.ResolveShardID()
+	}),
+}
+
 func init() {
-	epoch = time.Date(2019, time.January, 2, 15, 4, 5, 0, time.UTC)
-	slowIDGenerator = NewSnakeIDGenerator(DefaultShardBitSize, DefaultSeqBitSize, NextSecond, GetShardIDByIP, defaultCounter)
-	defaultIDGenerator = NewSnakeIDGenerator(DefaultShardBitSize, DefaultSeqBitSize, NextMilliseconds, GetShardIDByIP, defaultCounter)
-	fastIDGenerator = NewSnakeIDGenerator(DefaultShardBitSize, DefaultSeqBitSize>>1, NextMilliseconds, GetShardIDByIP, defaultCounter)
+	slowIDGenerator = NewSnakeIDGenerator(DefaultShardBitSize, DefaultSeqBitSize, NextSecond, defaultShardIDGetter, defaultCounter)
+	defaultIDGenerator = NewSnakeIDGenerator(DefaultShardBitSize, DefaultSeqBitSize, NextMilliseconds, defaultShardIDGetter, defaultCounter)
+	fastIDGenerator = NewSnakeIDGenerator(DefaultShardBitSize, DefaultSeqBitSize>>1, NextMilliseconds, defaultShardIDGetter, defaultCounter)
 }
 
 func ParseShortID(s string) (ID, error) {
@@ -62,6 +70,9 @@ func ParseShortID(s string) (ID, error) {
 		default:
 			return 0, errors.New("parse error")
 		}
+		if k > (math.MaxInt64-v)/62 {
+			return 0, ErrIDOverflow
+		}
 		k = k*62 + v
 	}
 	return ID(k), nil
@@ -77,9 +88,12 @@ func ParsePrettyID(s string) (ID, error) {
 	var k int64
 	for _, b := range bytes {
 		i := searchPrettyTable(b)
-		if i <= 0 {
+		if i < 0 {
 			return 0, errors.New("parse error")
 		}
+		if k > (math.MaxInt64-int64(i))/prettyTableSize {
+			return 0, ErrIDOverflow
+		}
 		k = k*prettyTableSize + int64(i)
 	}
 	return ID(k), nil
@@ -191,6 +205,26 @@ type NumberGetter interface {
 	GetNumber() int64
 }
 
+const (
+	ErrClockRollback     ErrorString = "clock moved backwards"
+	ErrSequenceExhausted ErrorString = "sequence exhausted"
+)
+
+// ClockRollbackPolicy decides how a strict SnakeIDGenerator behaves when the timestamp goes backwards
+type ClockRollbackPolicy int
+
+const (
+	// WaitOnClockRollback blocks until the clock catches up with the last issued timestamp
+	WaitOnClockRollback ClockRollbackPolicy = iota
+	// FailOnClockRollback returns ErrClockRollback
+	FailOnClockRollback
+	// LogicalOnClockRollback keeps issuing ids from the last timestamp, advancing it logically when its sequence is exhausted
+	LogicalOnClockRollback
+)
+
+// waitPollInterval is the sleep interval while waiting for the next tick
+const waitPollInterval = 100 * time.Microsecond
+
 type SnakeIDGenerator struct {
 	seqBitSize   uint
 	shardBitSize uint
@@ -198,6 +232,13 @@ type SnakeIDGenerator struct {
 	timestampGetter NumberGetter
 	shardIDGetter   NumberGetter
 	seqNumGetter    NumberGetter
+
+	mu              sync.Mutex
+	strict          bool
+	rollbackPolicy  ClockRollbackPolicy
+	failOnExhausted bool
+	lastTimestamp   int64
+	seq             int64
 }
 
 func NewSnakeIDGenerator(shardBitSize, seqBitSize uint, timestampGetter, shardIDGetter, seqNumGetter NumberGetter) *SnakeIDGenerator {
@@ -226,25 +267,130 @@ func NewSnakeIDGenerator(shardBitSize, seqBitSize uint, timestampGetter, shardID
 	}
 
 	return &SnakeIDGenerator{
-		seqBitSize,
-		shardBitSize,
-		timestampGetter,
-		shardIDGetter,
-		seqNumGetter,
+		seqBitSize:      seqBitSize,
+		shardBitSize:    shardBitSize,
+		timestampGetter: timestampGetter,
+		shardIDGetter:   shardIDGetter,
+		seqNumGetter:    seqNumGetter,
 	}
 }
 
+// EnableStrictMode makes g remember the last timestamp and generate sequence numbers per tick instead of using seqNumGetter.
+// When a tick's sequence is exhausted, g waits for the next tick, or returns ErrSequenceExhausted if failOnExhausted is true.
+// Clock regression is handled according to rollbackPolicy.
+func (g *SnakeIDGenerator) EnableStrictMode(rollbackPolicy ClockRollbackPolicy, failOnExhausted bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.strict = true
+	g.rollbackPolicy = rollbackPolicy
+	g.failOnExhausted = failOnExhausted
+	g.lastTimestamp = -1
+	g.seq = 0
+}
+
+// Clone returns a new generator with the same config as g. Strict mode state, e.g. last timestamp, is not shared
 func (g *SnakeIDGenerator) Clone() *SnakeIDGenerator {
-	return &(*g)
+	c := NewSnakeIDGenerator(g.shardBitSize, g.seqBitSize, g.timestampGetter, g.shardIDGetter, g.seqNumGetter)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.strict {
+		c.EnableStrictMode(g.rollbackPolicy, g.failOnExhausted)
+	}
+	return c
 }
 
+// NextID returns a new id. It panics if g is in strict mode and fails to generate an id, use TryNextID to handle the error
 func (g *SnakeIDGenerator) NextID() ID {
-	id := g.timestampGetter.GetNumber() << (g.seqBitSize + g.shardBitSize)
+	id, err := g.TryNextID()
+	if err != nil {
+		log.Panicf("Generate id: %v", err)
+	}
+	return id
+}
+
+// TryNextID returns a new id or an error if g is in strict mode and cannot generate a unique id
+func (g *SnakeIDGenerator) TryNextID() (ID, error) {
+	if g.isStrict() {
+		return g.nextStrictID()
+	}
+	return g.makeID(g.timestampGetter.GetNumber(), g.seqNumGetter.GetNumber())
+}
+
+func (g *SnakeIDGenerator) isStrict() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.strict
+}
+
+func (g *SnakeIDGenerator) makeID(timestamp, seq int64) (ID, error) {
+	id := timestamp << (g.seqBitSize + g.shardBitSize)
 	if g.shardBitSize > 0 {
-		id |= (g.shardIDGetter.GetNumber() % (1 << g.shardBitSize)) << g.seqBitSize
+		shardID, err := g.shardID()
+		if err != nil {
+			return 0, err
+		}
+		id |= (shardID % (1 << g.shardBitSize)) << g.seqBitSize
+	}
+	id |= seq % (1 << g.seqBitSize)
+	return ID(id), nil
+}
+
+func (g *SnakeIDGenerator) shardID() (int64, error) {
+	if r, ok := g.shardIDGetter.(ShardIDResolver); ok {
+		return r.ResolveShardID()
+	}
+	return g.shardIDGetter.GetNumber(), nil
+}
+
+func (g *SnakeIDGenerator) nextStrictID() (ID, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ts := g.timestampGetter.GetNumber()
+	borrowed := false
+	if ts < g.lastTimestamp {
+		switch g.rollbackPolicy {
+		case FailOnClockRollback:
+			return 0, fmt.Errorf("%w: %d ticks behind", ErrClockRollback, g.lastTimestamp-ts)
+		case LogicalOnClockRollback:
+			ts = g.lastTimestamp
+			borrowed = true
+		default:
+			ts = g.waitForTimestamp(g.lastTimestamp)
+		}
+	}
+
+	if ts == g.lastTimestamp {
+		if g.seq+1 < 1<<g.seqBitSize {
+			g.seq++
+			return g.makeID(ts, g.seq)
+		}
+
+		switch {
+		case g.failOnExhausted:
+			return 0, fmt.Errorf("%w: timestamp=%d", ErrSequenceExhausted, ts)
+		case borrowed:
+			ts++
+		default:
+			ts = g.waitForTimestamp(ts + 1)
+		}
+	}
+	id, err := g.makeID(ts, 0)
+	if err != nil {
+		return 0, err
+	}
+	g.lastTimestamp = ts
+	g.seq = 0
+	return id, nil
+}
+
+// waitForTimestamp blocks until timestampGetter returns a value no less than ts
+func (g *SnakeIDGenerator) waitForTimestamp(ts int64) int64 {
+	for {
+		if now := g.timestampGetter.GetNumber(); now >= ts {
+			return now
+		}
+		time.Sleep(waitPollInterval)
 	}
-	id |= g.seqNumGetter.GetNumber() % (1 << g.seqBitSize)
-	return ID(id)
 }
 
 type NumberGetterFunc func() int64
@@ -261,17 +407,12 @@ var NextMilliseconds NumberGetterFunc = func() int64 {
 	return time.Since(epoch).Nanoseconds() / 1e6
 }
 
+// GetShardIDByIP returns shard id resolved from the outbound ip, it panics if the outbound ip is unavailable
+// Deprecated: please use ShardIDByIP or other ShardIDResolver instead
 var GetShardIDByIP NumberGetterFunc = func() int64 {
-	ip, err := GetOutboundIP()
+	id, err := ShardIDByIP()
 	if err != nil {
-		log.Fatal(err)
-	}
-
-	ipBytes := []byte(ip)
-	var num int64 = 0
-	for i := 0; i < 8 && i < len(ipBytes); i++ {
-		num <<= 8
-		num |= int64(ipBytes[i])
+		log.Panic(err)
 	}
-	return num
+	return id
 }
@@ -0,0 +1,205 @@
+package gox
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+)
+
+const (
+	ErrUnknownIDKeyVersion ErrorString = "unknown id key version"
+)
+
+const idCipherRounds = 8
+
+// IDCipher is a keyed permutation of the non-negative int64 space, which is implemented by a Feistel network
+// with HMAC-SHA256 as round function and cycle walking.
+// It turns sequential ids into random-looking ones, which hide creation time and volume.
+type IDCipher struct {
+	key []byte
+}
+
+func NewIDCipher(key []byte) *IDCipher {
+	if len(key) == 0 {
+		panic("key is empty")
+	}
+	return &IDCipher{
+		key: append([]byte(nil), key...),
+	}
+}
+
+// Encrypt maps id to another non-negative id, it panics if id is negative
+func (c *IDCipher) Encrypt(id ID) ID {
+	if id < 0 {
+		panic("invalid id")
+	}
+	v := uint64(id)
+	for {
+		v = c.permute(v)
+		if v <= math.MaxInt64 {
+			return ID(v)
+		}
+	}
+}
+
+// Decrypt reverts Encrypt, it panics if id is negative
+func (c *IDCipher) Decrypt(id ID) ID {
+	if id < 0 {
+		panic("invalid id")
+	}
+	v := uint64(id)
+	for {
+		v = c.invert(v)
+		if v <= math.MaxInt64 {
+			return ID(v)
+		}
+	}
+}
+
+func (c *IDCipher) permute(v uint64) uint64 {
+	l, r := uint32(v>>32), uint32(v)
+	for i := 0; i < idCipherRounds; i++ {
+		l, r = r, l^c.round(i, r)
+	}
+	return uint64(l)<<32 | uint64(r)
+}
+
+func (c *IDCipher) invert(v uint64) uint64 {
+	l, r := uint32(v>>32), uint32(v)
+	for i := idCipherRounds - 1; i >= 0; i-- {
+		l, r = r^c.round(i, l), l
+	}
+	return uint64(l)<<32 | uint64(r)
+}
+
+func (c *IDCipher) round(i int, v uint32) uint32 {
+	var b [5]byte
+	b[0] = byte(i)
+	binary.BigEndian.PutUint32(b[1:], v)
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(b[:])
+	return binary.BigEndian.Uint32(mac.Sum(nil))
+}
+
+// IDObfuscator encodes ids into opaque public strings and decodes them back.
+// A public string is composed of a key version symbol and the encrypted id, which is padded to a fixed length,
+// followed by an optional check character which covers both the version and the encrypted id.
+// Keys can be rotated by adding a new version, strings encoded with old versions can still be decoded.
+type IDObfuscator struct {
+	alphabet *Alphabet
+	codec    *AlphabetIDCodec
+	checksum bool
+	// versionWeight is base^len(encrypted id) mod checkModulus, as version is the most significant digit in checksum
+	versionWeight int64
+
+	mu      sync.RWMutex
+	ciphers map[int]*IDCipher
+	version int
+}
+
+var _ IDCodec = (*IDObfuscator)(nil)
+
+// NewIDObfuscator creates an obfuscator which encodes with key of version.
+// version is encoded as alphabet's symbol, so it should be in [0, alphabet.Base())
+func NewIDObfuscator(alphabet *Alphabet, checksum bool, version int, key []byte) *IDObfuscator {
+	if alphabet == nil {
+		panic("alphabet is nil")
+	}
+	minLength := 1
+	for n := math.MaxInt64 / uint64(alphabet.Base()); n > 0; n /= uint64(alphabet.Base()) {
+		minLength++
+	}
+	if checksum && !alphabet.SupportsChecksum() {
+		panic("alphabet doesn't support checksum")
+	}
+	o := &IDObfuscator{
+		alphabet: alphabet,
+		codec:    NewIDCodec(alphabet, false, minLength),
+		checksum: checksum,
+		ciphers:  make(map[int]*IDCipher),
+	}
+	if checksum {
+		o.versionWeight = 1
+		for i := 0; i < minLength; i++ {
+			o.versionWeight = o.versionWeight * int64(alphabet.Base()) % alphabet.checkModulus()
+		}
+	}
+	o.AddKey(version, key)
+	o.version = version
+	return o
+}
+
+// AddKey adds key of version which can be used to decode
+func (o *IDObfuscator) AddKey(version int, key []byte) {
+	if version < 0 || version >= o.alphabet.Base() {
+		panic(fmt.Sprintf("version should be in [0, %d)", o.alphabet.Base()))
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.ciphers[version] = NewIDCipher(key)
+}
+
+// SetVersion sets version of key used to encode, the key must have been added
+func (o *IDObfuscator) SetVersion(version int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if _, ok := o.ciphers[version]; !ok {
+		panic(fmt.Sprintf("%s: %d", ErrUnknownIDKeyVersion, version))
+	}
+	o.version = version
+}
+
+// Encode returns the opaque string of id
+func (o *IDObfuscator) Encode(id ID) string {
+	o.mu.RLock()
+	version := o.version
+	c := o.ciphers[version]
+	o.mu.RUnlock()
+	encrypted := c.Encrypt(id)
+	s := string(o.alphabet.symbols[version]) + o.codec.Encode(encrypted)
+	if o.checksum {
+		s += string(o.alphabet.checkSymbol(o.checkValue(version, encrypted)))
+	}
+	return s
+}
+
+func (o *IDObfuscator) checkValue(version int, encrypted ID) int {
+	p := o.alphabet.checkModulus()
+	return int((int64(encrypted)%p + int64(version)*o.versionWeight) % p)
+}
+
+// Decode returns the id of opaque string s
+func (o *IDObfuscator) Decode(s string) (ID, error) {
+	var checkValue = -1
+	if o.checksum {
+		var err error
+		s, checkValue, err = o.alphabet.splitCheckSymbol(s)
+		if err != nil {
+			return 0, err
+		}
+	}
+	if len(s) < 2 {
+		return 0, fmt.Errorf("%w: too short", ErrInvalidIDSymbol)
+	}
+	version := o.alphabet.values[s[0]]
+	if version < 0 || version >= o.alphabet.Base() {
+		return 0, fmt.Errorf("%w: %c", ErrInvalidIDSymbol, s[0])
+	}
+	o.mu.RLock()
+	c := o.ciphers[version]
+	o.mu.RUnlock()
+	if c == nil {
+		return 0, fmt.Errorf("%w: %d", ErrUnknownIDKeyVersion, version)
+	}
+	id, err := o.codec.Decode(s[1:])
+	if err != nil {
+		return 0, err
+	}
+	if o.checksum && o.checkValue(version, id) != checkValue {
+		return 0, ErrInvalidIDChecksum
+	}
+	return c.Decrypt(id), nil
+}
@@ -0,0 +1,279 @@
+package gox
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	ErrInvalidIDSymbol   ErrorString = "invalid id symbol"
+	ErrInvalidIDChecksum ErrorString = "invalid id checksum"
+	ErrIDOverflow        ErrorString = "id overflow"
+)
+
+// Alphabet is an ordered set of symbols used to encode ids
+type Alphabet struct {
+	symbols      string
+	checkSymbols string
+	ignored      string
+	values       [256]int
+}
+
+type AlphabetOptions struct {
+	// CheckSymbols are extra symbols which are only used as check character.
+	// len(symbols)+len(CheckSymbols) must be a prime to detect single-character and adjacent transposition errors
+	CheckSymbols string
+	// CaseInsensitive makes lower and upper case letters equivalent while decoding
+	CaseInsensitive bool
+	// Aliases maps confusable symbols to symbols in alphabet while decoding, e.g. 'O' to '0'
+	Aliases map[byte]byte
+	// Ignored symbols are skipped while decoding, e.g. '-' used to separate groups
+	Ignored string
+}
+
+const (
+	invalidSymbolValue = -1
+	ignoredSymbolValue = -2
+)
+
+// NewAlphabet creates an alphabet, it panics if symbols are duplicated or opts is invalid
+func NewAlphabet(symbols string, opts *AlphabetOptions) *Alphabet {
+	if len(symbols) < 2 {
+		panic("alphabet should contain at least 2 symbols")
+	}
+	if opts == nil {
+		opts = &AlphabetOptions{}
+	}
+
+	a := &Alphabet{
+		symbols:      symbols,
+		checkSymbols: opts.CheckSymbols,
+		ignored:      opts.Ignored,
+	}
+	if len(a.checkSymbols) > 0 && !isPrime(len(a.symbols)+len(a.checkSymbols)) {
+		panic("len(symbols)+len(checkSymbols) should be a prime")
+	}
+
+	for i := range a.values {
+		a.values[i] = invalidSymbolValue
+	}
+	all := a.symbols + a.checkSymbols
+	for i := 0; i < len(all); i++ {
+		a.setValue(all[i], i, opts.CaseInsensitive)
+	}
+	for from, to := range opts.Aliases {
+		v := a.values[to]
+		if v == invalidSymbolValue {
+			panic(fmt.Sprintf("alias %c refers to undefined symbol %c", from, to))
+		}
+		a.setValue(from, v, opts.CaseInsensitive)
+	}
+	for i := 0; i < len(opts.Ignored); i++ {
+		a.setValue(opts.Ignored[i], ignoredSymbolValue, opts.CaseInsensitive)
+	}
+	return a
+}
+
+func (a *Alphabet) setValue(c byte, v int, caseInsensitive bool) {
+	if a.values[c] != invalidSymbolValue && a.values[c] != v {
+		panic(fmt.Sprintf("duplicate symbol %c", c))
+	}
+	a.values[c] = v
+	if !caseInsensitive {
+		return
+	}
+	switch {
+	case c >= 'a' && c <= 'z':
+		a.values[c-'a'+'A'] = v
+	case c >= 'A' && c <= 'Z':
+		a.values[c-'A'+'a'] = v
+	}
+}
+
+// Base returns the number of symbols
+func (a *Alphabet) Base() int {
+	return len(a.symbols)
+}
+
+// SupportsChecksum returns whether check character can be computed
+func (a *Alphabet) SupportsChecksum() bool {
+	return len(a.checkSymbols) > 0
+}
+
+func isPrime(n int) bool {
+	if n < 2 {
+		return false
+	}
+	for i := 2; i*i <= n; i++ {
+		if n%i == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	// CrockfordBase32Alphabet is Douglas Crockford's base32 alphabet, see https://www.crockford.com/base32.html
+	// It's case insensitive, decodes O as 0 and I/L as 1, ignores hyphens and uses *~$=U as check symbols
+	CrockfordBase32Alphabet = NewAlphabet("0123456789ABCDEFGHJKMNPQRSTVWXYZ", &AlphabetOptions{
+		CheckSymbols:    "*~$=U",
+		CaseInsensitive: true,
+		Aliases: map[byte]byte{
+			'O': '0',
+			'I': '1',
+			'L': '1',
+		},
+		Ignored: "-",
+	})
+
+	// Base58Alphabet is bitcoin's base58 alphabet without 0, O, I and l. ~ is used as check symbol
+	Base58Alphabet = NewAlphabet("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz", &AlphabetOptions{
+		CheckSymbols: "~",
+	})
+
+	// Base62Alphabet is the alphabet used by ID.ShortString
+	Base62Alphabet = NewAlphabet("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz", nil)
+)
+
+// IDCodec converts ids to strings and vice versa
+type IDCodec interface {
+	Encode(id ID) string
+	Decode(s string) (ID, error)
+}
+
+// AlphabetIDCodec encodes ids with an alphabet, optionally pads them to a minimum length and appends a check character
+type AlphabetIDCodec struct {
+	alphabet  *Alphabet
+	checksum  bool
+	minLength int
+}
+
+var _ IDCodec = (*AlphabetIDCodec)(nil)
+
+// NewIDCodec creates an IDCodec. If checksum is true, a check character is appended, which detects single-character
+// and adjacent transposition errors. Encoded strings are left padded with alphabet's first symbol to minLength,
+// excluding the check character.
+func NewIDCodec(alphabet *Alphabet, checksum bool, minLength int) *AlphabetIDCodec {
+	if alphabet == nil {
+		panic("alphabet is nil")
+	}
+	if checksum && !alphabet.SupportsChecksum() {
+		panic("alphabet doesn't support checksum")
+	}
+	if minLength < 0 {
+		panic("minLength should be non-negative")
+	}
+	return &AlphabetIDCodec{
+		alphabet:  alphabet,
+		checksum:  checksum,
+		minLength: minLength,
+	}
+}
+
+// Encode returns string representation of id, it panics if id is negative
+func (c *AlphabetIDCodec) Encode(id ID) string {
+	if id < 0 {
+		panic("invalid id")
+	}
+	base := int64(c.alphabet.Base())
+	bytes := make([]byte, max(64, c.minLength))
+	n := len(bytes)
+	for k := int64(id); k > 0 || n == len(bytes); k /= base {
+		n--
+		bytes[n] = c.alphabet.symbols[k%base]
+	}
+	for len(bytes)-n < c.minLength {
+		n--
+		bytes[n] = c.alphabet.symbols[0]
+	}
+	s := string(bytes[n:])
+	if c.checksum {
+		s += string(c.checkSymbol(int64(id)))
+	}
+	return s
+}
+
+// Decode parses s into id
+func (c *AlphabetIDCodec) Decode(s string) (ID, error) {
+	var checkValue = -1
+	if c.checksum {
+		var err error
+		s, checkValue, err = c.alphabet.splitCheckSymbol(s)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	base := int64(c.alphabet.Base())
+	var k int64
+	var n int
+	for i := 0; i < len(s); i++ {
+		v := c.alphabet.values[s[i]]
+		if v == ignoredSymbolValue {
+			continue
+		}
+		if v < 0 || int64(v) >= base {
+			return 0, fmt.Errorf("%w: %c", ErrInvalidIDSymbol, s[i])
+		}
+		if k > (math.MaxInt64-int64(v))/base {
+			return 0, ErrIDOverflow
+		}
+		k = k*base + int64(v)
+		n++
+	}
+
+	if n == 0 {
+		return 0, fmt.Errorf("%w: empty", ErrInvalidIDSymbol)
+	}
+
+	if c.checksum && c.checkValue(k) != checkValue {
+		return 0, ErrInvalidIDChecksum
+	}
+	return ID(k), nil
+}
+
+// checkValue returns k mod p, p is the prime number of symbols and check symbols.
+// As base < p, a single-character error changes k by d*base^i and an adjacent transposition changes k by
+// d*(base-1)*base^i, neither of which is divisible by p
+func (c *AlphabetIDCodec) checkValue(k int64) int {
+	return int(k % c.alphabet.checkModulus())
+}
+
+func (c *AlphabetIDCodec) checkSymbol(k int64) byte {
+	return c.alphabet.checkSymbol(c.checkValue(k))
+}
+
+func (a *Alphabet) checkModulus() int64 {
+	return int64(len(a.symbols) + len(a.checkSymbols))
+}
+
+func (a *Alphabet) checkSymbol(v int) byte {
+	if v < len(a.symbols) {
+		return a.symbols[v]
+	}
+	return a.checkSymbols[v-len(a.symbols)]
+}
+
+// splitCheckSymbol removes trailing ignored symbols and the check symbol from s
+func (a *Alphabet) splitCheckSymbol(s string) (string, int, error) {
+	s = strings.TrimRight(s, a.ignored)
+	if len(s) == 0 {
+		return "", 0, fmt.Errorf("%w: empty", ErrInvalidIDSymbol)
+	}
+	v := a.values[s[len(s)-1]]
+	if v < 0 {
+		return "", 0, fmt.Errorf("%w: %c", ErrInvalidIDSymbol, s[len(s)-1])
+	}
+	return s[:len(s)-1], v, nil
+}
+
+// Format returns string representation of i encoded by codec
+func (i ID) Format(codec IDCodec) string {
+	return codec.Encode(i)
+}
+
+// ParseID parses s encoded by codec
+func ParseID(codec IDCodec, s string) (ID, error) {
+	return codec.Decode(s)
+}
@@ -0,0 +1,64 @@
+package gox
+
+import "time"
+
+// IDLayout describes how an id generated by SnakeIDGenerator is composed: time+shard+seq
+type IDLayout struct {
+	ShardBitSize uint
+	SeqBitSize   uint
+	// Epoch is the time of timestamp 0
+	Epoch time.Time
+	// Unit is the duration of one timestamp tick, e.g. time.Millisecond or time.Second
+	Unit time.Duration
+}
+
+// Layouts of ids returned by NextID, NextSlowID and NextFastID
+var (
+	DefaultIDLayout = &IDLayout{
+		ShardBitSize: DefaultShardBitSize,
+		SeqBitSize:   DefaultSeqBitSize,
+		Epoch:        epoch,
+		Unit:         time.Millisecond,
+	}
+
+	SlowIDLayout = &IDLayout{
+		ShardBitSize: DefaultShardBitSize,
+		SeqBitSize:   DefaultSeqBitSize,
+		Epoch:        epoch,
+		Unit:         time.Second,
+	}
+
+	FastIDLayout = &IDLayout{
+		ShardBitSize: DefaultShardBitSize,
+		SeqBitSize:   DefaultSeqBitSize >> 1,
+		Epoch:        epoch,
+		Unit:         time.Millisecond,
+	}
+)
+
+// Decode splits id into creation time, shard and sequence number
+func (l *IDLayout) Decode(id ID) (t time.Time, shard int64, seq int64) {
+	k := int64(id)
+	seq = KeepRightBits(k, l.SeqBitSize)
+	k >>= l.SeqBitSize
+	shard = KeepRightBits(k, l.ShardBitSize)
+	k >>= l.ShardBitSize
+	t = l.Epoch.Add(time.Duration(k) * l.Unit)
+	return
+}
+
+// IDRangeForTime returns the min and max id which may be created in [start, end]
+// It can be used to translate time filters into id range filters
+func (l *IDLayout) IDRangeForTime(start, end time.Time) (min ID, max ID) {
+	lowBitSize := l.ShardBitSize + l.SeqBitSize
+	min = ID(l.timestamp(start) << lowBitSize)
+	max = ID(l.timestamp(end)<<lowBitSize | (1<<lowBitSize - 1))
+	return
+}
+
+func (l *IDLayout) timestamp(t time.Time) int64 {
+	if t.Before(l.Epoch) {
+		return 0
+	}
+	return int64(t.Sub(l.Epoch) / l.Unit)
+}
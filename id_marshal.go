@@ -0,0 +1,234 @@
+package gox
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IDFormat is the wire format of ID in JSON and text
+type IDFormat int
+
+const (
+	// IDNumberFormat encodes ID as JSON number, which may lose precision in JavaScript if ID exceeds 2^53
+	IDNumberFormat IDFormat = iota
+	// IDDecimalFormat encodes ID as decimal string
+	IDDecimalFormat
+	// IDShortFormat encodes ID as ShortString
+	IDShortFormat
+	// IDPrettyFormat encodes ID as PrettyString
+	IDPrettyFormat
+)
+
+// IDWireFormat is the format used to marshal ID. Use NumberID, DecimalID, ShortID or PrettyID to select format per field.
+var IDWireFormat = IDNumberFormat
+
+var _ json.Marshaler = ID(0)
+var _ json.Unmarshaler = (*ID)(nil)
+var _ encoding.TextMarshaler = ID(0)
+var _ encoding.TextUnmarshaler = (*ID)(nil)
+var _ sql.Scanner = (*ID)(nil)
+var _ driver.Valuer = ID(0)
+
+func (i ID) MarshalJSON() ([]byte, error) {
+	return i.marshalJSON(IDWireFormat)
+}
+
+// UnmarshalJSON accepts number, decimal string, short string and pretty string, see parseString
+func (i *ID) UnmarshalJSON(data []byte) error {
+	return i.unmarshalJSON(data, IDWireFormat)
+}
+
+func (i ID) MarshalText() ([]byte, error) {
+	return []byte(i.formatString(IDWireFormat)), nil
+}
+
+func (i *ID) UnmarshalText(text []byte) error {
+	return i.parseString(string(text), IDWireFormat)
+}
+
+// Scan accepts integer and decimal string
+func (i *ID) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*i = 0
+		return nil
+	case int64:
+		*i = ID(v)
+		return nil
+	case []byte:
+		return i.scanDecimal(string(v))
+	case string:
+		return i.scanDecimal(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ID", src)
+	}
+}
+
+func (i *ID) scanDecimal(s string) error {
+	id, err := parseDecimalID(s)
+	if err != nil {
+		return fmt.Errorf("parse id %s: %w", s, err)
+	}
+	*i = id
+	return nil
+}
+
+// Value returns ID as int64 regardless of IDWireFormat, as ids are usually stored in BIGINT columns
+func (i ID) Value() (driver.Value, error) {
+	return int64(i), nil
+}
+
+// formatString formats i in f. Negative ids, and ids whose short or pretty string consists of digits only,
+// are formatted in decimal, hence parseString can always tell decimal strings from others
+func (i ID) formatString(f IDFormat) string {
+	if i < 0 {
+		f = IDDecimalFormat
+	}
+	var s string
+	switch f {
+	case IDShortFormat:
+		s = i.ShortString()
+	case IDPrettyFormat:
+		s = i.PrettyString()
+	}
+	if s == "" || isDecimalIDString(s) {
+		return strconv.FormatInt(int64(i), 10)
+	}
+	return s
+}
+
+func (i ID) marshalJSON(f IDFormat) ([]byte, error) {
+	if f == IDNumberFormat {
+		return []byte(strconv.FormatInt(int64(i), 10)), nil
+	}
+	return json.Marshal(i.formatString(f))
+}
+
+func (i *ID) unmarshalJSON(data []byte, f IDFormat) error {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return fmt.Errorf("%w: empty", ErrInvalidIDSymbol)
+	}
+	if string(data) == "null" {
+		return nil
+	}
+	if data[0] != '"' {
+		k, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return fmt.Errorf("parse id %s: %w", data, err)
+		}
+		*i = ID(k)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return i.parseString(s, f)
+}
+
+// parseString parses s in any string format. Digits-only s is decimal. s containing symbols which are not used
+// by PrettyString, e.g. lowercase letters, is short. Otherwise s is parsed in f if it's short or pretty format,
+// or rejected as ambiguous
+func (i *ID) parseString(s string, f IDFormat) error {
+	var parse func(s string) (ID, error)
+	switch {
+	case isDecimalIDString(s):
+		parse = parseDecimalID
+	case !isPrettyIDString(s):
+		parse = ParseShortID
+	case f == IDShortFormat:
+		parse = ParseShortID
+	case f == IDPrettyFormat:
+		parse = ParsePrettyID
+	default:
+		return fmt.Errorf("parse id %s: %w: ambiguous short or pretty string", s, ErrInvalidIDSymbol)
+	}
+	id, err := parse(s)
+	if err != nil {
+		return fmt.Errorf("parse id %s: %w", s, err)
+	}
+	*i = id
+	return nil
+}
+
+// isDecimalIDString reports whether s is an optional minus sign followed by digits
+func isDecimalIDString(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isPrettyIDString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if searchPrettyTable(s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func parseDecimalID(s string) (ID, error) {
+	k, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return ID(k), nil
+}
+
+// NumberID is marshaled as JSON number regardless of IDWireFormat
+type NumberID ID
+
+func (i NumberID) MarshalJSON() ([]byte, error) {
+	return ID(i).marshalJSON(IDNumberFormat)
+}
+
+func (i *NumberID) UnmarshalJSON(data []byte) error {
+	return (*ID)(i).unmarshalJSON(data, IDNumberFormat)
+}
+
+// DecimalID is marshaled as decimal string regardless of IDWireFormat
+type DecimalID ID
+
+func (i DecimalID) MarshalJSON() ([]byte, error) {
+	return ID(i).marshalJSON(IDDecimalFormat)
+}
+
+func (i *DecimalID) UnmarshalJSON(data []byte) error {
+	return (*ID)(i).unmarshalJSON(data, IDDecimalFormat)
+}
+
+// ShortID is marshaled as ShortString regardless of IDWireFormat
+type ShortID ID
+
+func (i ShortID) MarshalJSON() ([]byte, error) {
+	return ID(i).marshalJSON(IDShortFormat)
+}
+
+func (i *ShortID) UnmarshalJSON(data []byte) error {
+	return (*ID)(i).unmarshalJSON(data, IDShortFormat)
+}
+
+// PrettyID is marshaled as PrettyString regardless of IDWireFormat
+type PrettyID ID
+
+func (i PrettyID) MarshalJSON() ([]byte, error) {
+	return ID(i).marshalJSON(IDPrettyFormat)
+}
+
+func (i *PrettyID) UnmarshalJSON(data []byte) error {
+	return (*ID)(i).unmarshalJSON(data, IDPrettyFormat)
+}
@@ -1,8 +1,15 @@
 package gox
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestID(t *testing.T) {
@@ -64,3 +71,443 @@ func TestNumberGetterFunc_GetNumber(t *testing.T) {
 	t.Logf("%0X %d", i1, i1)
 
 }
+
+func TestSnakeIDGenerator_Strict(t *testing.T) {
+	t.Run("Unique", func(t *testing.T) {
+		g := NewSnakeIDGenerator(DefaultShardBitSize, DefaultSeqBitSize, NextMilliseconds, NumberGetterFunc(func() int64 {
+			return 1
+		}), defaultCounter)
+		g.EnableStrictMode(WaitOnClockRollback, false)
+
+		const numWorkers = 8
+		const numIDs = 2000
+		ids := make(chan ID, numWorkers*numIDs)
+		var wg sync.WaitGroup
+		for i := 0; i < numWorkers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < numIDs; j++ {
+					ids <- g.NextID()
+				}
+			}()
+		}
+		wg.Wait()
+		close(ids)
+
+		seen := make(map[ID]bool, numWorkers*numIDs)
+		for id := range ids {
+			if seen[id] {
+				t.Fatalf("duplicate id: %d", id)
+			}
+			seen[id] = true
+		}
+	})
+
+	t.Run("SequenceExhausted", func(t *testing.T) {
+		g := NewSnakeIDGenerator(0, 2, NumberGetterFunc(func() int64 {
+			return 10
+		}), nil, defaultCounter)
+		g.EnableStrictMode(WaitOnClockRollback, true)
+		for i := 0; i < 4; i++ {
+			if _, err := g.TryNextID(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := g.TryNextID(); !errors.Is(err, ErrSequenceExhausted) {
+			t.Fatalf("expected ErrSequenceExhausted, got %v", err)
+		}
+	})
+
+	t.Run("RollbackFail", func(t *testing.T) {
+		var now int64 = 10
+		g := NewSnakeIDGenerator(0, 2, NumberGetterFunc(func() int64 {
+			return atomic.LoadInt64(&now)
+		}), nil, defaultCounter)
+		g.EnableStrictMode(FailOnClockRollback, false)
+		if _, err := g.TryNextID(); err != nil {
+			t.Fatal(err)
+		}
+		atomic.StoreInt64(&now, 5)
+		if _, err := g.TryNextID(); !errors.Is(err, ErrClockRollback) {
+			t.Fatalf("expected ErrClockRollback, got %v", err)
+		}
+	})
+
+	t.Run("RollbackLogical", func(t *testing.T) {
+		var now int64 = 10
+		g := NewSnakeIDGenerator(0, 2, NumberGetterFunc(func() int64 {
+			return atomic.LoadInt64(&now)
+		}), nil, defaultCounter)
+		g.EnableStrictMode(LogicalOnClockRollback, false)
+		last, err := g.TryNextID()
+		if err != nil {
+			t.Fatal(err)
+		}
+		atomic.StoreInt64(&now, 5)
+		for i := 0; i < 10; i++ {
+			id, err := g.TryNextID()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id <= last {
+				t.Fatalf("expected increasing ids: %d <= %d", id, last)
+			}
+			last = id
+		}
+	})
+
+	t.Run("RollbackWait", func(t *testing.T) {
+		var now int64 = 10
+		g := NewSnakeIDGenerator(0, 2, NumberGetterFunc(func() int64 {
+			return atomic.LoadInt64(&now)
+		}), nil, defaultCounter)
+		g.EnableStrictMode(WaitOnClockRollback, false)
+		first := g.NextID()
+		atomic.StoreInt64(&now, 5)
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			atomic.StoreInt64(&now, 11)
+		}()
+		if id := g.NextID(); id <= first {
+			t.Fatalf("expected increasing ids: %d <= %d", id, first)
+		}
+	})
+
+	t.Run("Clone", func(t *testing.T) {
+		g := NewSnakeIDGenerator(0, 2, NumberGetterFunc(func() int64 {
+			return 10
+		}), nil, defaultCounter)
+		g.EnableStrictMode(WaitOnClockRollback, true)
+		for i := 0; i < 4; i++ {
+			if _, err := g.TryNextID(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		c := g.Clone()
+		if c == g {
+			t.Fatal("expected a new generator")
+		}
+		if _, err := c.TryNextID(); err != nil {
+			t.Fatalf("expected fresh sequence in clone, got %v", err)
+		}
+		if _, err := g.TryNextID(); !errors.Is(err, ErrSequenceExhausted) {
+			t.Fatalf("expected ErrSequenceExhausted, got %v", err)
+		}
+		for i := 0; i < 3; i++ {
+			if _, err := c.TryNextID(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := c.TryNextID(); !errors.Is(err, ErrSequenceExhausted) {
+			t.Fatalf("expected clone to keep strict mode, got %v", err)
+		}
+	})
+}
+
+func TestIDLayout(t *testing.T) {
+	l := DefaultIDLayout
+	ts := NextMilliseconds()
+	g := NewSnakeIDGenerator(l.ShardBitSize, l.SeqBitSize, NumberGetterFunc(func() int64 {
+		return ts
+	}), NumberGetterFunc(func() int64 {
+		return 5
+	}), defaultCounter)
+	g.EnableStrictMode(WaitOnClockRollback, false)
+	g.NextID()
+	id := g.NextID()
+
+	created, shard, seq := l.Decode(id)
+	if shard != 5 || seq != 1 {
+		t.Fatalf("shard=%d seq=%d", shard, seq)
+	}
+	if want := epoch.Add(time.Duration(ts) * time.Millisecond); !created.Equal(want) {
+		t.Fatalf("time=%v, want %v", created, want)
+	}
+
+	min, max := l.IDRangeForTime(created, created)
+	if id < min || id > max {
+		t.Fatalf("%d is not in [%d, %d]", id, min, max)
+	}
+	if _, _, seq := l.Decode(max); seq != 1<<l.SeqBitSize-1 {
+		t.Fatalf("max seq=%d", seq)
+	}
+}
+
+func TestIDCodec(t *testing.T) {
+	t.Run("Crockford", func(t *testing.T) {
+		c := NewIDCodec(CrockfordBase32Alphabet, true, 0)
+		for _, id := range []ID{0, 1, 31, 32, 1234, math.MaxInt64, NextID()} {
+			s := id.Format(c)
+			parsed, err := ParseID(c, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed != id {
+				t.Fatalf("%s: %d != %d", s, parsed, id)
+			}
+			parsed, err = ParseID(c, strings.ToLower(s))
+			if err != nil || parsed != id {
+				t.Fatalf("%s: %d %v", s, parsed, err)
+			}
+		}
+
+		id, err := ParseID(c, "1o-IT")
+		if err != nil || id != 32*32+1 {
+			t.Fatalf("%d %v", id, err)
+		}
+	})
+
+	t.Run("Typos", func(t *testing.T) {
+		for _, a := range []*Alphabet{CrockfordBase32Alphabet, Base58Alphabet} {
+			c := NewIDCodec(a, true, 0)
+			s := []byte(NextID().Format(c))
+			for i := 0; i < len(s)-1; i++ {
+				orig := s[i]
+				for j := 0; j < len(a.symbols); j++ {
+					if a.symbols[j] == orig {
+						continue
+					}
+					s[i] = a.symbols[j]
+					if _, err := ParseID(c, string(s)); err == nil {
+						t.Fatalf("undetected typo: %s", s)
+					}
+				}
+				s[i] = orig
+
+				if i+2 < len(s) && s[i] != s[i+1] {
+					s[i], s[i+1] = s[i+1], s[i]
+					if _, err := ParseID(c, string(s)); err == nil {
+						t.Fatalf("undetected transposition: %s", s)
+					}
+					s[i], s[i+1] = s[i+1], s[i]
+				}
+			}
+		}
+	})
+
+	t.Run("Padding", func(t *testing.T) {
+		c := NewIDCodec(Base58Alphabet, false, 8)
+		s := ID(58).Format(c)
+		if s != "11111121" {
+			t.Fatal(s)
+		}
+		if id, err := ParseID(c, s); err != nil || id != 58 {
+			t.Fatalf("%d %v", id, err)
+		}
+	})
+
+	t.Run("LongPadding", func(t *testing.T) {
+		c := NewIDCodec(CrockfordBase32Alphabet, true, 100)
+		s := ID(1 << 62).Format(c)
+		if len(s) != 101 {
+			t.Fatal(s)
+		}
+		if id, err := ParseID(c, s); err != nil || id != 1<<62 {
+			t.Fatalf("%d %v", id, err)
+		}
+	})
+
+	t.Run("Overflow", func(t *testing.T) {
+		c := NewIDCodec(Base62Alphabet, false, 0)
+		if _, err := ParseID(c, "zzzzzzzzzzzzzzzz"); !errors.Is(err, ErrIDOverflow) {
+			t.Fatal(err)
+		}
+	})
+}
+
+func TestIDObfuscator(t *testing.T) {
+	o := NewIDObfuscator(CrockfordBase32Alphabet, true, 1, []byte("secret1"))
+	ids := []ID{0, 1, 2, math.MaxInt64, NextID(), NextID()}
+	encoded := make([]string, len(ids))
+	for i, id := range ids {
+		s := o.Encode(id)
+		encoded[i] = s
+		if len(s) != len(encoded[0]) {
+			t.Fatalf("%s: expected length %d", s, len(encoded[0]))
+		}
+		decoded, err := o.Decode(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded != id {
+			t.Fatalf("%s: %d != %d", s, decoded, id)
+		}
+	}
+
+	o.AddKey(2, []byte("secret2"))
+	o.SetVersion(2)
+	for i, id := range ids {
+		s := o.Encode(id)
+		if s == encoded[i] {
+			t.Fatalf("%s: expected different encoding", s)
+		}
+		if decoded, err := o.Decode(encoded[i]); err != nil || decoded != id {
+			t.Fatalf("%s: %d %v", encoded[i], decoded, err)
+		}
+		if decoded, err := o.Decode(s); err != nil || decoded != id {
+			t.Fatalf("%s: %d %v", s, decoded, err)
+		}
+	}
+
+	if _, err := o.Decode("3" + encoded[0][1:]); !errors.Is(err, ErrUnknownIDKeyVersion) {
+		t.Fatal(err)
+	}
+
+	// mistyped version is detected by checksum even though the key of that version exists
+	for _, s := range encoded {
+		if _, err := o.Decode("2" + s[1:]); !errors.Is(err, ErrInvalidIDChecksum) {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if s[1] != s[0] {
+			swapped := string(s[1]) + string(s[0]) + s[2:]
+			if decoded, err := o.Decode(swapped); err == nil {
+				t.Fatalf("%s: decoded %d from %s", s, decoded, swapped)
+			}
+		}
+	}
+}
+
+func TestID_JSON(t *testing.T) {
+	type Item struct {
+		ID       ID        `json:"id"`
+		Decimal  DecimalID `json:"decimal"`
+		Short    ShortID   `json:"short"`
+		Pretty   PrettyID  `json:"pretty"`
+		Number   NumberID  `json:"number"`
+		Optional *ID       `json:"optional,omitempty"`
+	}
+
+	id := ID(math.MaxInt64 - 1)
+	item := &Item{
+		ID:      id,
+		Decimal: DecimalID(id),
+		Short:   ShortID(id),
+		Pretty:  PrettyID(id),
+		Number:  NumberID(id),
+	}
+
+	for _, f := range []IDFormat{IDNumberFormat, IDDecimalFormat, IDShortFormat, IDPrettyFormat} {
+		IDWireFormat = f
+		b, err := json.Marshal(item)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := fmt.Sprintf(`{"id":%s,"decimal":"%d","short":"%s","pretty":"%s","number":%d}`,
+			id.formatJSONForTest(f), id, id.ShortString(), id.PrettyString(), id)
+		if string(b) != expected {
+			t.Fatalf("got %s, want %s", b, expected)
+		}
+
+		var item2 *Item
+		if err = json.Unmarshal(b, &item2); err != nil {
+			t.Fatal(err)
+		}
+		if *item2 != *item {
+			t.Fatalf("got %v, want %v", item2, item)
+		}
+	}
+	IDWireFormat = IDNumberFormat
+
+	var v ID
+	for _, s := range []string{`123`, `"123"`} {
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			t.Fatal(s, err)
+		}
+		if v != 123 {
+			t.Fatalf("%s: got %d", s, v)
+		}
+	}
+	// short string is detected by symbols which are not used by pretty string
+	if err := json.Unmarshal([]byte(`"1z"`), &v); err != nil || v != 123 {
+		t.Fatal(v, err)
+	}
+	// uppercase string is either short or pretty
+	if err := json.Unmarshal([]byte(`"4M"`), &v); !errors.Is(err, ErrInvalidIDSymbol) {
+		t.Fatal(v, err)
+	}
+
+	var sv ShortID
+	if err := json.Unmarshal([]byte(`"1z"`), &sv); err != nil || sv != 123 {
+		t.Fatal(sv, err)
+	}
+	if err := json.Unmarshal([]byte(`"-5"`), &sv); err != nil || sv != -5 {
+		t.Fatal(sv, err)
+	}
+
+	var pv PrettyID
+	if err := json.Unmarshal([]byte(`"4M"`), &pv); err != nil || pv != 123 {
+		t.Fatal(pv, err)
+	}
+	// digits-only string is decimal in any format
+	for _, s := range []string{`123`, `"123"`} {
+		if err := json.Unmarshal([]byte(s), &pv); err != nil || pv != 123 {
+			t.Fatal(s, pv, err)
+		}
+		if err := json.Unmarshal([]byte(s), &sv); err != nil || sv != 123 {
+			t.Fatal(s, sv, err)
+		}
+	}
+	if err := json.Unmarshal([]byte(`"1z"`), &pv); err != nil || pv != 123 {
+		t.Fatal(pv, err)
+	}
+
+	// ids whose pretty or short string has digits only are marshaled in decimal to be parsed back
+	for _, id := range []ID{36, 5, 62*62 + 1} {
+		for _, f := range []IDFormat{IDShortFormat, IDPrettyFormat} {
+			b, err := id.marshalJSON(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var parsed ID
+			if err = parsed.unmarshalJSON(b, f); err != nil || parsed != id {
+				t.Fatalf("%s: %d %v", b, parsed, err)
+			}
+		}
+	}
+
+	if err := v.Scan([]byte("123")); err != nil || v != 123 {
+		t.Fatal(v, err)
+	}
+	if err := v.Scan([]byte("1z")); err == nil {
+		t.Fatal("expected error")
+	}
+	if dv, err := v.Value(); err != nil || dv != int64(123) {
+		t.Fatal(dv, err)
+	}
+}
+
+func TestParseID_Overflow(t *testing.T) {
+	if _, err := ParseShortID("zzzzzzzzzzzz"); !errors.Is(err, ErrIDOverflow) {
+		t.Fatal(err)
+	}
+	if _, err := ParsePrettyID("ZZZZZZZZZZZZZZZ"); !errors.Is(err, ErrIDOverflow) {
+		t.Fatal(err)
+	}
+	if id, err := ParseShortID(ID(math.MaxInt64).ShortString()); err != nil || id != math.MaxInt64 {
+		t.Fatal(id, err)
+	}
+	if id, err := ParsePrettyID(ID(math.MaxInt64).PrettyString()); err != nil || id != math.MaxInt64 {
+		t.Fatal(id, err)
+	}
+}
+
+// '1' is the first symbol of pretty table, whose index 0 was rejected by ParsePrettyID
+func TestParsePrettyID_FirstSymbol(t *testing.T) {
+	for _, id := range []ID{0, prettyTableSize, prettyTableSize*prettyTableSize + 1} {
+		s := id.PrettyString()
+		if !strings.Contains(s, "1") {
+			t.Fatalf("%d: %s doesn't contain 1", id, s)
+		}
+		if parsed, err := ParsePrettyID(s); err != nil || parsed != id {
+			t.Fatalf("%s: %d %v", s, parsed, err)
+		}
+	}
+}
+
+func (i ID) formatJSONForTest(f IDFormat) string {
+	if f == IDNumberFormat {
+		return fmt.Sprint(int64(i))
+	}
+	return `"` + i.formatString(f) + `"`
+}
@@ -0,0 +1,374 @@
+package gox
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	ErrInvalidPatch    ErrorString = "invalid patch"
+	ErrPathNotFound    ErrorString = "path not found"
+	ErrPatchTestFailed ErrorString = "patch test failed"
+	ErrNilDocument     ErrorString = "document is nil"
+)
+
+// PatchError is returned by Merge, ApplyMergePatch and ApplyJSONPatch
+type PatchError struct {
+	// Index is index of failed operation in JSON patch, -1 if not applicable
+	Index int
+	Op    string
+	Path  string
+	Err   error
+}
+
+func (e *PatchError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Op)
+	if e.Index >= 0 {
+		b.WriteString(" #" + strconv.Itoa(e.Index))
+	}
+	if e.Path != "" {
+		b.WriteString(" " + e.Path)
+	}
+	b.WriteString(": " + e.Err.Error())
+	return b.String()
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// JSON patch operations defined by RFC 6902
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+	PatchMove    = "move"
+	PatchCopy    = "copy"
+	PatchTest    = "test"
+)
+
+// JSONPatchOp is an operation of JSON patch
+type JSONPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+
+	// noValue is true if value member is absent in parsed operation
+	noValue bool
+}
+
+func (o *JSONPatchOp) MarshalJSON() ([]byte, error) {
+	type op JSONPatchOp
+	switch o.Op {
+	case PatchAdd, PatchReplace, PatchTest:
+		// value may be null which must not be omitted
+		return json.Marshal(struct {
+			*op
+			Value interface{} `json:"value"`
+		}{(*op)(o), o.Value})
+	default:
+		return json.Marshal((*op)(o))
+	}
+}
+
+func (o *JSONPatchOp) UnmarshalJSON(data []byte) error {
+	type op JSONPatchOp
+	var j struct {
+		*op
+		Value json.RawMessage `json:"value"`
+	}
+	j.op = (*op)(o)
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	o.Value = nil
+	o.noValue = j.Value == nil
+	if o.noValue {
+		return nil
+	}
+	return json.Unmarshal(j.Value, &o.Value)
+}
+
+// JSONPatch is defined by RFC 6902
+type JSONPatch []*JSONPatchOp
+
+// ParseJSONPatch parses JSON patch document
+func ParseJSONPatch(data []byte) (JSONPatch, error) {
+	var p JSONPatch
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, &PatchError{Index: -1, Op: "parse", Err: fmt.Errorf("%w: %v", ErrInvalidPatch, err)}
+	}
+	return p, nil
+}
+
+// Apply applies all operations to m. m is not changed if any operation fails
+func (p JSONPatch) Apply(m M) error {
+	if m == nil {
+		return &PatchError{Index: -1, Op: "apply", Err: ErrNilDocument}
+	}
+	doc := deepCopy(m).(M)
+	for i, o := range p {
+		if o == nil {
+			return &PatchError{Index: i, Err: fmt.Errorf("%w: null operation", ErrInvalidPatch)}
+		}
+		if err := applyPatchOp(doc, o); err != nil {
+			return &PatchError{Index: i, Op: o.Op, Path: o.Path, Err: err}
+		}
+	}
+	replaceContent(m, doc)
+	return nil
+}
+
+// ApplyJSONPatch applies JSON patch document defined by RFC 6902. m is not changed if any operation fails
+func (m M) ApplyJSONPatch(patch []byte) error {
+	p, err := ParseJSONPatch(patch)
+	if err != nil {
+		return err
+	}
+	return p.Apply(m)
+}
+
+func applyPatchOp(doc M, o *JSONPatchOp) error {
+	switch o.Op {
+	case PatchAdd, PatchReplace, PatchTest:
+		// value is required, though it may be null
+		if o.noValue {
+			return fmt.Errorf("%w: missing value", ErrInvalidPatch)
+		}
+	}
+	switch o.Op {
+	case PatchAdd:
+		return patchAdd(doc, o.Path, deepCopy(o.Value))
+	case PatchRemove:
+		_, err := patchRemove(doc, o.Path)
+		return err
+	case PatchReplace:
+		if o.Path == "" {
+			return patchAdd(doc, o.Path, deepCopy(o.Value))
+		}
+		if _, err := patchRemove(doc, o.Path); err != nil {
+			return err
+		}
+		return patchAdd(doc, o.Path, deepCopy(o.Value))
+	case PatchMove:
+		if o.From == o.Path {
+			_, err := patchGet(doc, o.From)
+			return err
+		}
+		if strings.HasPrefix(o.Path, o.From+"/") {
+			return fmt.Errorf("%w: cannot move %s into its child", ErrInvalidPatch, o.From)
+		}
+		v, err := patchRemove(doc, o.From)
+		if err != nil {
+			return err
+		}
+		return patchAdd(doc, o.Path, v)
+	case PatchCopy:
+		v, err := patchGet(doc, o.From)
+		if err != nil {
+			return err
+		}
+		return patchAdd(doc, o.Path, deepCopy(v))
+	case PatchTest:
+		v, err := patchGet(doc, o.Path)
+		if err != nil {
+			return err
+		}
+		if !jsonEqual(v, o.Value) {
+			return ErrPatchTestFailed
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, o.Op)
+	}
+}
+
+func patchGet(doc M, pointer string) (interface{}, error) {
+	elems, err := resolvePointer(doc, pointer, false)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := getPath(doc, elems)
+	if !ok {
+		return nil, ErrPathNotFound
+	}
+	return v, nil
+}
+
+func patchAdd(doc M, pointer string, v interface{}) error {
+	elems, err := resolvePointer(doc, pointer, true)
+	if err != nil {
+		return err
+	}
+	if len(elems) == 0 {
+		vm, ok := toStringMap(v)
+		if !ok {
+			return fmt.Errorf("%w: document must be an object", ErrInvalidPatch)
+		}
+		replaceContent(doc, vm)
+		return nil
+	}
+
+	n := len(elems)
+	last := elems[n-1]
+	if !last.isIndex {
+		_, err = setPath(doc, elems, 0, v)
+		return err
+	}
+	parent, _ := getPath(doc, elems[:n-1])
+	rv := reflect.ValueOf(parent)
+	if last.index > rv.Len() {
+		return fmt.Errorf("%w: index %d out of range", ErrPathNotFound, last.index)
+	}
+	s := reflect.MakeSlice(rv.Type(), rv.Len()+1, rv.Len()+1)
+	reflect.Copy(s, rv.Slice(0, last.index))
+	reflect.Copy(s.Slice(last.index+1, s.Len()), rv.Slice(last.index, rv.Len()))
+	if err = assignValue(s.Index(last.index), v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	_, err = setPath(doc, elems[:n-1], 0, s.Interface())
+	return err
+}
+
+func patchRemove(doc M, pointer string) (interface{}, error) {
+	elems, err := resolvePointer(doc, pointer, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(elems) == 0 {
+		return nil, fmt.Errorf("%w: cannot remove document", ErrInvalidPatch)
+	}
+	v, ok := getPath(doc, elems)
+	if !ok || !deletePath(doc, elems) {
+		return nil, ErrPathNotFound
+	}
+	return v, nil
+}
+
+// resolvePointer converts JSON pointer defined by RFC 6901 to path elements.
+// Numeric tokens are treated as index or key according to the container in doc
+func resolvePointer(doc M, pointer string, allowEnd bool) ([]pathElem, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("%w: pointer must start with /", ErrInvalidPath)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	elems := make([]pathElem, 0, len(tokens))
+	var cur interface{} = doc
+	for i, tok := range tokens {
+		tok = strings.Replace(strings.Replace(tok, "~1", "/", -1), "~0", "~", -1)
+		last := i == len(tokens)-1
+		if _, ok := toStringMap(cur); ok {
+			elems = append(elems, pathElem{key: tok})
+		} else if rv := reflect.ValueOf(cur); rv.Kind() == reflect.Slice {
+			idx := rv.Len()
+			if tok != "-" || !last || !allowEnd {
+				var err error
+				idx, err = strconv.Atoi(tok)
+				if err != nil || idx < 0 || strconv.Itoa(idx) != tok {
+					return nil, fmt.Errorf("%w: invalid index %q", ErrInvalidPath, tok)
+				}
+			}
+			elems = append(elems, pathElem{index: idx, isIndex: true})
+		} else {
+			return nil, ErrPathNotFound
+		}
+		if !last {
+			var ok bool
+			if cur, ok = getPath(cur, elems[i:]); !ok {
+				return nil, ErrPathNotFound
+			}
+		}
+	}
+	return elems, nil
+}
+
+func replaceContent(dst M, src map[string]interface{}) {
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+}
+
+// DiffM returns JSON patch which transforms a into b
+func DiffM(a, b M) JSONPatch {
+	var p JSONPatch
+	diffMap(&p, "", a, b)
+	return p
+}
+
+func diffMap(p *JSONPatch, prefix string, a, b map[string]interface{}) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		path := prefix + "/" + escapePointerToken(k)
+		av, inA := a[k]
+		bv, inB := b[k]
+		switch {
+		case !inB:
+			*p = append(*p, &JSONPatchOp{Op: PatchRemove, Path: path})
+		case !inA:
+			*p = append(*p, &JSONPatchOp{Op: PatchAdd, Path: path, Value: deepCopy(bv)})
+		default:
+			diffValue(p, path, av, bv)
+		}
+	}
+}
+
+func diffValue(p *JSONPatch, path string, a, b interface{}) {
+	if jsonEqual(a, b) {
+		return
+	}
+	if am, ok := toStringMap(a); ok {
+		if bm, ok := toStringMap(b); ok {
+			diffMap(p, path, am, bm)
+			return
+		}
+	}
+	if as, ok := toSlice(a); ok {
+		if bs, ok := toSlice(b); ok {
+			diffSlice(p, path, as, bs)
+			return
+		}
+	}
+	*p = append(*p, &JSONPatchOp{Op: PatchReplace, Path: path, Value: deepCopy(b)})
+}
+
+func diffSlice(p *JSONPatch, path string, a, b []interface{}) {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for i := 0; i < n; i++ {
+		diffValue(p, path+"/"+strconv.Itoa(i), a[i], b[i])
+	}
+	for i := n; i < len(b); i++ {
+		*p = append(*p, &JSONPatchOp{Op: PatchAdd, Path: path + "/-", Value: deepCopy(b[i])})
+	}
+	// remove from tail so that indexes stay valid
+	for i := len(a) - 1; i >= n; i-- {
+		*p = append(*p, &JSONPatchOp{Op: PatchRemove, Path: path + "/" + strconv.Itoa(i)})
+	}
+}
+
+func escapePointerToken(s string) string {
+	return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
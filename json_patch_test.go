@@ -0,0 +1,159 @@
+package gox_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gopub/gox"
+	"github.com/stretchr/testify/require"
+)
+
+func parseM(t *testing.T, s string) gox.M {
+	var m gox.M
+	require.NoError(t, json.Unmarshal([]byte(s), &m))
+	return m
+}
+
+func TestM_Merge(t *testing.T) {
+	newDst := func() gox.M {
+		return parseM(t, `{"name":"Tom","profile":{"age":20,"tags":["a","b"]},"items":[{"id":1,"n":1},{"id":2,"n":2}]}`)
+	}
+	src := parseM(t, `{"profile":{"age":21,"tags":["b","c"]},"items":[{"id":2,"n":3},{"id":3,"n":3}]}`)
+
+	t.Run("Replace", func(t *testing.T) {
+		m := newDst()
+		require.NoError(t, m.Merge(src, nil))
+		require.Equal(t, parseM(t, `{"name":"Tom","profile":{"age":21,"tags":["b","c"]},"items":[{"id":2,"n":3},{"id":3,"n":3}]}`).JSON(), m.JSON())
+	})
+
+	t.Run("Append", func(t *testing.T) {
+		m := newDst()
+		require.NoError(t, m.Merge(src, &gox.MergeOptions{Array: gox.AppendArray}))
+		tags, _ := m.GetSlice("profile.tags")
+		require.Equal(t, []interface{}{"a", "b", "b", "c"}, tags)
+	})
+
+	t.Run("UnionByKey", func(t *testing.T) {
+		m := newDst()
+		require.NoError(t, m.Merge(src, &gox.MergeOptions{Array: gox.UnionArray, Key: "id"}))
+		tags, _ := m.GetSlice("profile.tags")
+		require.Equal(t, []interface{}{"a", "b", "c"}, tags)
+		require.Equal(t, `[{"id":1,"n":1},{"id":2,"n":3},{"id":3,"n":3}]`, jsonString(t, m["items"]))
+	})
+
+	t.Run("InvalidStrategy", func(t *testing.T) {
+		err := newDst().Merge(src, &gox.MergeOptions{Array: 10})
+		require.True(t, errors.Is(err, gox.ErrInvalidPatch))
+	})
+}
+
+func TestM_ApplyMergePatch(t *testing.T) {
+	// example from RFC 7396
+	m := parseM(t, `{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`)
+	err := m.ApplyMergePatch([]byte(`{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"]}`))
+	require.NoError(t, err)
+	require.Equal(t, parseM(t, `{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"This will be unchanged","phoneNumber":"+01-123-456-7890"}`).JSON(), m.JSON())
+
+	require.NoError(t, m.ApplyMergePatch([]byte(`{"a":{"b":{"c":null,"d":1}}}`)))
+	require.Equal(t, `{"b":{"d":1}}`, jsonString(t, m["a"]))
+
+	err = m.ApplyMergePatch([]byte(`[1]`))
+	var pe *gox.PatchError
+	require.True(t, errors.As(err, &pe))
+	require.True(t, errors.Is(err, gox.ErrInvalidPatch))
+}
+
+func TestM_ApplyJSONPatch(t *testing.T) {
+	m := parseM(t, `{"foo":["bar","baz"],"a":{"b":"c"},"x~y":1}`)
+	err := m.ApplyJSONPatch([]byte(`[
+		{"op":"test","path":"/a/b","value":"c"},
+		{"op":"add","path":"/foo/1","value":"qux"},
+		{"op":"add","path":"/foo/-","value":"end"},
+		{"op":"remove","path":"/foo/0"},
+		{"op":"replace","path":"/a/b","value":null},
+		{"op":"copy","from":"/a","path":"/a2"},
+		{"op":"move","from":"/x~0y","path":"/a/n"},
+		{"op":"test","path":"/a/n","value":1.0}
+	]`))
+	require.NoError(t, err)
+	require.Equal(t, parseM(t, `{"foo":["qux","baz","end"],"a":{"b":null,"n":1},"a2":{"b":null}}`).JSON(), m.JSON())
+
+	t.Run("Atomic", func(t *testing.T) {
+		before := m.JSON()
+		err := m.ApplyJSONPatch([]byte(`[{"op":"remove","path":"/foo"},{"op":"test","path":"/a2/b","value":1}]`))
+		var pe *gox.PatchError
+		require.True(t, errors.As(err, &pe))
+		require.Equal(t, 1, pe.Index)
+		require.Equal(t, "test", pe.Op)
+		require.True(t, errors.Is(err, gox.ErrPatchTestFailed))
+		require.Equal(t, before, m.JSON())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		err := m.ApplyJSONPatch([]byte(`[{"op":"remove","path":"/missing"}]`))
+		require.True(t, errors.Is(err, gox.ErrPathNotFound))
+		err = m.ApplyJSONPatch([]byte(`[{"op":"add","path":"/foo/9","value":1}]`))
+		require.True(t, errors.Is(err, gox.ErrPathNotFound))
+		err = m.ApplyJSONPatch([]byte(`[{"op":"move","from":"/a","path":"/a/b/c"}]`))
+		require.True(t, errors.Is(err, gox.ErrInvalidPatch))
+		err = m.ApplyJSONPatch([]byte(`[{"op":"bad","path":"/a"}]`))
+		require.True(t, errors.Is(err, gox.ErrInvalidPatch))
+		for _, op := range []string{"add", "replace", "test"} {
+			err = m.ApplyJSONPatch([]byte(`[{"op":"` + op + `","path":"/a/b"}]`))
+			require.True(t, errors.Is(err, gox.ErrInvalidPatch), op)
+		}
+	})
+
+	t.Run("NilDocument", func(t *testing.T) {
+		var nilM gox.M
+		require.NotPanics(t, func() {
+			err := nilM.ApplyJSONPatch([]byte(`[{"op":"add","path":"/a","value":1}]`))
+			require.True(t, errors.Is(err, gox.ErrNilDocument))
+			err = nilM.ApplyMergePatch([]byte(`{"a":1}`))
+			require.True(t, errors.Is(err, gox.ErrNilDocument))
+			err = nilM.Merge(gox.M{"a": 1}, nil)
+			require.True(t, errors.Is(err, gox.ErrNilDocument))
+		})
+	})
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		p := gox.JSONPatch{{Op: gox.PatchAdd, Path: "/n", Value: nil}}
+		data, err := json.Marshal(p)
+		require.NoError(t, err)
+		p, err = gox.ParseJSONPatch(data)
+		require.NoError(t, err)
+		m := gox.M{}
+		require.NoError(t, p.Apply(m))
+		v, ok := m["n"]
+		require.True(t, ok)
+		require.Nil(t, v)
+	})
+}
+
+func TestDiffM(t *testing.T) {
+	a := parseM(t, `{"name":"Tom","age":20,"tags":["a","b","c"],"profile":{"city":"Beijing","zip":"100000"},"x/y":1}`)
+	b := parseM(t, `{"name":"Tom","age":21,"tags":["a","d"],"profile":{"city":"Shanghai"},"email":null}`)
+	p := gox.DiffM(a, b)
+	data, err := json.Marshal(p)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"op":"replace","path":"/age","value":21},
+		{"op":"add","path":"/email","value":null},
+		{"op":"replace","path":"/profile/city","value":"Shanghai"},
+		{"op":"remove","path":"/profile/zip"},
+		{"op":"replace","path":"/tags/1","value":"d"},
+		{"op":"remove","path":"/tags/2"},
+		{"op":"remove","path":"/x~1y"}
+	]`, string(data))
+
+	require.NoError(t, a.ApplyJSONPatch(data))
+	require.Equal(t, b.JSON(), a.JSON())
+	require.Empty(t, gox.DiffM(a, b))
+}
+
+func jsonString(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -2,6 +2,7 @@ package gox
 
 import (
 	"encoding/json"
+	"fmt"
 	"math/big"
 	"net/url"
 	"reflect"
@@ -476,6 +477,24 @@ func (m M) JSON() string {
 	return string(data)
 }
 
+// MarshalCodec implements codec.Marshaler
+func (m M) MarshalCodec() (interface{}, error) {
+	return map[string]interface{}(m), nil
+}
+
+// UnmarshalCodec implements codec.Unmarshaler
+func (m *M) UnmarshalCodec(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		*m = nil
+	case map[string]interface{}:
+		*m = v
+	default:
+		return fmt.Errorf("cannot unmarshal %T into M", v)
+	}
+	return nil
+}
+
 func (m M) RemoveEmptyValues() {
 	m.RemoveSomeEmptyValues(nil)
 }
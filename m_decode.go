@@ -0,0 +1,304 @@
+package gox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	phoneNumberType   = reflect.TypeOf(PhoneNumber{})
+	jsonUnmarshalType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// Decode decodes m into struct pointed by dst. Fields are named by json tag, and these tags are supported:
+//
+//	default:"value"  value used if field is missing or null
+//	required:"true"  field must be present and not null
+//	format:"date"    parse yyyy-mm-dd into time.Time, see M.Date
+//	format:"phone"   parse into PhoneNumber, see M.PhoneNumber, or check string
+//	format:"email"   check string by any format registered by RegisterSchemaFormat
+//
+// All field errors are returned together in *ValidationError
+func (m M) Decode(dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("dst must be a non-nil pointer to struct")
+	}
+	e := &ValidationError{}
+	decodeStruct(e, "", m, rv.Elem())
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+func decodeStruct(e *ValidationError, path string, m map[string]interface{}, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts := parseJSONTag(f.Tag.Get("json"))
+		if name == "-" && opts == "" {
+			continue
+		}
+
+		fv := v.Field(i)
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if f.Type.Kind() == reflect.Ptr {
+					if f.PkgPath != "" {
+						// cannot allocate unexported embedded pointer
+						continue
+					}
+					if fv.IsNil() {
+						fv.Set(reflect.New(ft))
+					}
+					fv = fv.Elem()
+				}
+				decodeStruct(e, path, m, fv)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		key, raw := lookupField(m, name)
+		fieldPath := joinPath(path, key)
+		if raw == nil {
+			def, ok := f.Tag.Lookup("default")
+			if !ok {
+				if f.Tag.Get("required") == "true" {
+					e.Add(fieldPath, "is required")
+				}
+				continue
+			}
+			raw = def
+		}
+		decodeValue(e, fieldPath, raw, fv, f.Tag.Get("format"))
+	}
+}
+
+func parseJSONTag(tag string) (name, opts string) {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+// lookupField finds key exactly, then case-insensitively like encoding/json
+func lookupField(m map[string]interface{}, name string) (string, interface{}) {
+	if v, ok := m[name]; ok {
+		return name, v
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return k, v
+		}
+	}
+	return name, nil
+}
+
+func decodeValue(e *ValidationError, path string, raw interface{}, v reflect.Value, format string) {
+	if v.Kind() == reflect.Ptr {
+		if raw == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		ev := reflect.New(v.Type().Elem())
+		n := len(e.Violations)
+		decodeValue(e, path, raw, ev.Elem(), format)
+		if len(e.Violations) == n {
+			v.Set(ev)
+		}
+		return
+	}
+
+	if format != "" && decodeFormat(e, path, raw, v, format) {
+		return
+	}
+
+	if reflect.PtrTo(v.Type()).Implements(jsonUnmarshalType) || v.Type() == timeType {
+		decodeJSON(e, path, raw, v)
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		switch s := raw.(type) {
+		case string:
+			v.SetString(s)
+		case json.Number:
+			v.SetString(string(s))
+		default:
+			e.Add(path, "must be string")
+		}
+	case reflect.Bool:
+		b, err := ParseBool(raw)
+		if err != nil {
+			e.Add(path, "must be boolean")
+			return
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := ParseInt(raw)
+		if err != nil || !isIntegral(raw) {
+			e.Add(path, "must be integer")
+			return
+		}
+		if v.OverflowInt(i) {
+			e.Add(path, "overflows %v", v.Type())
+			return
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := ParseInt(raw)
+		if err != nil || !isIntegral(raw) || i < 0 {
+			e.Add(path, "must be non-negative integer")
+			return
+		}
+		if v.OverflowUint(uint64(i)) {
+			e.Add(path, "overflows %v", v.Type())
+			return
+		}
+		v.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		f, err := ParseFloat(raw)
+		if err != nil {
+			if i, ierr := ParseInt(raw); ierr == nil {
+				f, err = float64(i), nil
+			}
+		}
+		if err != nil {
+			e.Add(path, "must be number")
+			return
+		}
+		if v.OverflowFloat(f) {
+			e.Add(path, "overflows %v", v.Type())
+			return
+		}
+		v.SetFloat(f)
+	case reflect.Struct:
+		sm, ok := toStringMap(raw)
+		if !ok {
+			e.Add(path, "must be object")
+			return
+		}
+		decodeStruct(e, path, sm, v)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			decodeJSON(e, path, raw, v)
+			return
+		}
+		a, ok := toSlice(raw)
+		if !ok {
+			e.Add(path, "must be array")
+			return
+		}
+		s := reflect.MakeSlice(v.Type(), len(a), len(a))
+		for i, ev := range a {
+			decodeValue(e, fmt.Sprintf("%s[%d]", path, i), ev, s.Index(i), format)
+		}
+		v.Set(s)
+	case reflect.Map:
+		sm, ok := toStringMap(raw)
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			e.Add(path, "must be object")
+			return
+		}
+		mv := reflect.MakeMapWithSize(v.Type(), len(sm))
+		for k, ev := range sm {
+			vv := reflect.New(v.Type().Elem()).Elem()
+			decodeValue(e, joinPath(path, k), ev, vv, format)
+			mv.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), vv)
+		}
+		v.Set(mv)
+	case reflect.Interface:
+		if raw == nil {
+			v.Set(reflect.Zero(v.Type()))
+			return
+		}
+		rv := reflect.ValueOf(raw)
+		if !rv.Type().AssignableTo(v.Type()) {
+			e.Add(path, "cannot assign %T to %v", raw, v.Type())
+			return
+		}
+		v.Set(rv)
+	default:
+		decodeJSON(e, path, raw, v)
+	}
+}
+
+// decodeFormat decodes raw with format, returns false if v is not handled
+func decodeFormat(e *ValidationError, path string, raw interface{}, v reflect.Value, format string) bool {
+	single := M{"v": raw}
+	switch {
+	case format == "date" && v.Type() == timeType:
+		t, ok := single.Date("v")
+		if !ok {
+			e.Add(path, "must be date in format yyyy-mm-dd")
+			return true
+		}
+		v.Set(reflect.ValueOf(t))
+		return true
+	case format == "phone" && v.Type() == phoneNumberType:
+		pn := single.PhoneNumber("v")
+		if pn == nil {
+			e.Add(path, "must be valid phone")
+			return true
+		}
+		v.Set(reflect.ValueOf(*pn))
+		return true
+	case v.Kind() == reflect.String:
+		isValid := getSchemaFormat(format)
+		if isValid == nil {
+			e.Add(path, "unknown format %s", format)
+			return true
+		}
+		s, ok := raw.(string)
+		if !ok {
+			e.Add(path, "must be string")
+			return true
+		}
+		if !isValid(strings.TrimSpace(s)) {
+			e.Add(path, "must be valid %s", format)
+			return true
+		}
+		v.SetString(strings.TrimSpace(s))
+		return true
+	default:
+		return false
+	}
+}
+
+func decodeJSON(e *ValidationError, path string, raw interface{}, v reflect.Value) {
+	data, err := json.Marshal(raw)
+	if err == nil {
+		err = json.Unmarshal(data, v.Addr().Interface())
+	}
+	if s, ok := raw.(string); ok && err != nil {
+		// default tag value may be JSON literal, e.g. number
+		err = json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+	if err != nil {
+		e.Add(path, "invalid value: %v", err)
+	}
+}
+
+func isIntegral(raw interface{}) bool {
+	if f, ok := schemaNumber(raw); ok {
+		return f == float64(int64(f))
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package gox_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gopub/gox"
+	"github.com/stretchr/testify/require"
+)
+
+type decodeAddress struct {
+	City string `json:"city" required:"true"`
+	Zip  string `json:"zip" default:"000000"`
+}
+
+type decodeBase struct {
+	ID gox.ID `json:"id"`
+}
+
+type decodeUser struct {
+	decodeBase
+	Name      string            `json:"name" required:"true"`
+	Email     string            `json:"email" format:"email"`
+	Phone     *gox.PhoneNumber  `json:"phone" format:"phone"`
+	Birthday  time.Time         `json:"birthday" format:"date"`
+	CreatedAt time.Time         `json:"created_at"`
+	Age       int8              `json:"age" default:"18"`
+	Score     float64           `json:"score"`
+	VIP       bool              `json:"vip"`
+	Tags      []string          `json:"tags"`
+	Addresses []*decodeAddress  `json:"addresses"`
+	Extra     map[string]int    `json:"extra"`
+	Any       interface{}       `json:"any"`
+	Ignored   string            `json:"-"`
+	Nickname  string            `default:"anonymous"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+func TestM_Decode(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		m := parseM(t, `{
+			"id": 10,
+			"name": "Tom",
+			"email": " tom@example.com ",
+			"phone": "+8613800000000",
+			"birthday": "1990-01-02",
+			"created_at": "2020-01-02T03:04:05Z",
+			"score": 9.5,
+			"vip": "true",
+			"tags": ["a", "b"],
+			"addresses": [{"city": "Beijing"}],
+			"extra": {"k": 1},
+			"any": [1],
+			"Ignored": "x",
+			"NICKNAME": "tommy"
+		}`)
+		var u decodeUser
+		require.NoError(t, m.Decode(&u))
+		require.Equal(t, gox.ID(10), u.ID)
+		require.Equal(t, "Tom", u.Name)
+		require.Equal(t, "tom@example.com", u.Email)
+		require.Equal(t, &gox.PhoneNumber{Code: 86, Number: 13800000000}, u.Phone)
+		require.Equal(t, time.Date(1990, 1, 2, 0, 0, 0, 0, time.UTC), u.Birthday)
+		require.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), u.CreatedAt.UTC())
+		require.Equal(t, int8(18), u.Age)
+		require.Equal(t, 9.5, u.Score)
+		require.True(t, u.VIP)
+		require.Equal(t, []string{"a", "b"}, u.Tags)
+		require.Equal(t, []*decodeAddress{{City: "Beijing", Zip: "000000"}}, u.Addresses)
+		require.Equal(t, map[string]int{"k": 1}, u.Extra)
+		require.Equal(t, []interface{}{float64(1)}, u.Any)
+		require.Empty(t, u.Ignored)
+		require.Equal(t, "tommy", u.Nickname)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		m := parseM(t, `{
+			"email": "tom",
+			"phone": "123",
+			"birthday": "1990/01/02",
+			"age": 300,
+			"score": "high",
+			"tags": "a",
+			"addresses": [{"zip": "1"}, 1]
+		}`)
+		var u decodeUser
+		err := m.Decode(&u)
+		var ve *gox.ValidationError
+		require.True(t, errors.As(err, &ve))
+		fields := map[string]string{}
+		for _, v := range ve.Violations {
+			fields[v.Field] = v.Description
+		}
+		require.Equal(t, map[string]string{
+			"name":              "is required",
+			"email":             "must be valid email",
+			"phone":             "must be valid phone",
+			"birthday":          "must be date in format yyyy-mm-dd",
+			"age":               "overflows int8",
+			"score":             "must be number",
+			"tags":              "must be array",
+			"addresses[0].city": "is required",
+			"addresses[1]":      "must be object",
+		}, fields)
+	})
+
+	t.Run("NullInterface", func(t *testing.T) {
+		m := parseM(t, `{"name": "Tom", "any": null, "items": [1, null], "props": {"a": null}}`)
+		var v struct {
+			Name  string                 `json:"name"`
+			Any   interface{}            `json:"any"`
+			Items []interface{}          `json:"items"`
+			Props map[string]interface{} `json:"props"`
+		}
+		require.NotPanics(t, func() {
+			require.NoError(t, m.Decode(&v))
+		})
+		require.Nil(t, v.Any)
+		require.Len(t, v.Items, 2)
+		require.Nil(t, v.Items[1])
+		val, ok := v.Props["a"]
+		require.True(t, ok)
+		require.Nil(t, val)
+	})
+
+	require.Error(t, gox.M{}.Decode(decodeUser{}))
+}
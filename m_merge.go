@@ -0,0 +1,247 @@
+package gox
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// ArrayMergeStrategy decides how Merge combines arrays
+type ArrayMergeStrategy int
+
+const (
+	// ReplaceArray replaces destination array with source array
+	ReplaceArray ArrayMergeStrategy = iota
+	// AppendArray appends source elements to destination array
+	AppendArray
+	// UnionArray appends source elements which are not in destination array.
+	// If MergeOptions.Key is set, map elements with the same key are merged
+	UnionArray
+)
+
+func (s ArrayMergeStrategy) String() string {
+	switch s {
+	case ReplaceArray:
+		return "replace"
+	case AppendArray:
+		return "append"
+	case UnionArray:
+		return "union"
+	default:
+		return fmt.Sprintf("ArrayMergeStrategy(%d)", int(s))
+	}
+}
+
+// MergeOptions customizes Merge
+type MergeOptions struct {
+	Array ArrayMergeStrategy
+	// Key identifies map elements in arrays for UnionArray
+	Key string
+}
+
+// Merge deep merges src into m. Nested maps are merged recursively, arrays are merged by opts.Array,
+// and other values in src replace values in m. nil opts means ReplaceArray
+func (m M) Merge(src M, opts *MergeOptions) error {
+	var o MergeOptions
+	if opts != nil {
+		o = *opts
+	}
+	if m == nil {
+		return &PatchError{Index: -1, Op: "merge", Err: ErrNilDocument}
+	}
+	switch o.Array {
+	case ReplaceArray, AppendArray, UnionArray:
+	default:
+		return &PatchError{Index: -1, Op: "merge", Err: fmt.Errorf("%w: unknown array strategy %v", ErrInvalidPatch, o.Array)}
+	}
+	mergeMap(m, src, &o)
+	return nil
+}
+
+func mergeMap(dst, src map[string]interface{}, o *MergeOptions) {
+	for k, sv := range src {
+		dst[k] = mergeValue(dst[k], sv, o)
+	}
+}
+
+func mergeValue(dv, sv interface{}, o *MergeOptions) interface{} {
+	if sm, ok := toStringMap(sv); ok {
+		if dm, ok := toStringMap(dv); ok {
+			mergeMap(dm, sm, o)
+			return dv
+		}
+		return deepCopy(sv)
+	}
+
+	ss, ok := toSlice(sv)
+	if !ok || o.Array == ReplaceArray {
+		return deepCopy(sv)
+	}
+	ds, ok := toSlice(dv)
+	if !ok {
+		return deepCopy(sv)
+	}
+	res := append(make([]interface{}, 0, len(ds)+len(ss)), ds...)
+	if o.Array == AppendArray {
+		for _, v := range ss {
+			res = append(res, deepCopy(v))
+		}
+		return res
+	}
+
+	for _, v := range ss {
+		i := indexOfElement(res, v, o.Key)
+		if i < 0 {
+			res = append(res, deepCopy(v))
+		} else if o.Key != "" {
+			res[i] = mergeValue(res[i], v, o)
+		}
+	}
+	return res
+}
+
+func indexOfElement(a []interface{}, v interface{}, key string) int {
+	if key != "" {
+		if vm, ok := toStringMap(v); ok {
+			kv, ok := vm[key]
+			if !ok {
+				return -1
+			}
+			for i, e := range a {
+				if em, ok := toStringMap(e); ok {
+					if ek, ok := em[key]; ok && jsonEqual(ek, kv) {
+						return i
+					}
+				}
+			}
+			return -1
+		}
+	}
+	for i, e := range a {
+		if jsonEqual(e, v) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ApplyMergePatch applies JSON merge patch defined by RFC 7396
+func (m M) ApplyMergePatch(patch []byte) error {
+	if m == nil {
+		return &PatchError{Index: -1, Op: "merge", Err: ErrNilDocument}
+	}
+	var p interface{}
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return &PatchError{Index: -1, Op: "merge", Err: fmt.Errorf("%w: %v", ErrInvalidPatch, err)}
+	}
+	pm, ok := p.(map[string]interface{})
+	if !ok {
+		// non-object patch replaces the whole document which must be an object
+		return &PatchError{Index: -1, Op: "merge", Err: fmt.Errorf("%w: patch is not an object", ErrInvalidPatch)}
+	}
+	mergePatch(m, pm)
+	return nil
+}
+
+func mergePatch(target, patch map[string]interface{}) {
+	for k, pv := range patch {
+		if pv == nil {
+			delete(target, k)
+			continue
+		}
+		pm, ok := pv.(map[string]interface{})
+		if !ok {
+			target[k] = pv
+			continue
+		}
+		tm, ok := toStringMap(target[k])
+		if !ok {
+			tm = M{}
+			target[k] = tm
+		}
+		mergePatch(tm, pm)
+	}
+}
+
+func toStringMap(v interface{}) (map[string]interface{}, bool) {
+	switch mv := v.(type) {
+	case M:
+		return mv, true
+	case map[string]interface{}:
+		return mv, true
+	default:
+		return nil, false
+	}
+}
+
+func toSlice(v interface{}) ([]interface{}, bool) {
+	if s, ok := v.([]interface{}); ok {
+		return s, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice || rv.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	s := make([]interface{}, rv.Len())
+	for i := range s {
+		s[i] = rv.Index(i).Interface()
+	}
+	return s, true
+}
+
+// deepCopy copies maps and slices recursively
+func deepCopy(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case M:
+		c := make(M, len(tv))
+		for k, e := range tv {
+			c[k] = deepCopy(e)
+		}
+		return c
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(tv))
+		for k, e := range tv {
+			c[k] = deepCopy(e)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(tv))
+		for i, e := range tv {
+			c[i] = deepCopy(e)
+		}
+		return c
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice && !rv.IsNil() {
+		c := reflect.MakeSlice(rv.Type(), rv.Len(), rv.Len())
+		reflect.Copy(c, rv)
+		return c.Interface()
+	}
+	return v
+}
+
+// jsonEqual reports whether a and b have the same JSON representation regardless of Go types
+func jsonEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+	na, err := normalizeJSON(a)
+	if err != nil {
+		return false
+	}
+	nb, err := normalizeJSON(b)
+	if err != nil {
+		return false
+	}
+	return reflect.DeepEqual(na, nb)
+}
+
+func normalizeJSON(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var n interface{}
+	err = json.Unmarshal(data, &n)
+	return n, err
+}
@@ -0,0 +1,371 @@
+package gox
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const ErrInvalidPath ErrorString = "invalid path"
+
+// pathElem is a map key or a slice index in path
+type pathElem struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parsePath parses path like a.b[2].c or a["key.with.dot"][0]
+func parsePath(path string) ([]pathElem, error) {
+	if path == "" {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidPath)
+	}
+	var elems []pathElem
+	for i := 0; i < len(path); {
+		switch c := path[i]; {
+		case c == '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: missing ] in %s", ErrInvalidPath, path)
+			}
+			s := path[i+1 : i+end]
+			if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+				elems = append(elems, pathElem{key: s[1 : len(s)-1]})
+			} else {
+				n, err := strconv.Atoi(s)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("%w: invalid index %s in %s", ErrInvalidPath, s, path)
+				}
+				elems = append(elems, pathElem{index: n, isIndex: true})
+			}
+			i += end + 1
+			if i < len(path) && path[i] == '.' {
+				i++
+				if i == len(path) {
+					return nil, fmt.Errorf("%w: trailing . in %s", ErrInvalidPath, path)
+				}
+			}
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("%w: empty key in %s", ErrInvalidPath, path)
+			}
+			elems = append(elems, pathElem{key: path[i : i+end]})
+			i += end
+			if i < len(path) && path[i] == '.' {
+				i++
+				if i == len(path) {
+					return nil, fmt.Errorf("%w: trailing . in %s", ErrInvalidPath, path)
+				}
+			}
+		}
+	}
+	return elems, nil
+}
+
+func formatPath(elems []pathElem) string {
+	var b strings.Builder
+	for i, e := range elems {
+		switch {
+		case e.isIndex:
+			b.WriteString("[" + strconv.Itoa(e.index) + "]")
+		case strings.ContainsAny(e.key, ".[]"):
+			b.WriteString(`["` + e.key + `"]`)
+		default:
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(e.key)
+		}
+	}
+	return b.String()
+}
+
+func getPath(v interface{}, elems []pathElem) (interface{}, bool) {
+	for _, e := range elems {
+		if e.isIndex {
+			rv := reflect.ValueOf(v)
+			if (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) || e.index >= rv.Len() {
+				return nil, false
+			}
+			v = rv.Index(e.index).Interface()
+			continue
+		}
+
+		var ok bool
+		switch mv := v.(type) {
+		case M:
+			v, ok = mv[e.key]
+		case map[string]interface{}:
+			v, ok = mv[e.key]
+		default:
+			rv := reflect.ValueOf(v)
+			if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+				return nil, false
+			}
+			ev := rv.MapIndex(reflect.ValueOf(e.key).Convert(rv.Type().Key()))
+			if ok = ev.IsValid(); ok {
+				v = ev.Interface()
+			}
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+// setPath sets v at elems in container c and returns the container which may be created or appended
+func setPath(c interface{}, elems []pathElem, depth int, v interface{}) (interface{}, error) {
+	if depth == len(elems) {
+		return v, nil
+	}
+	e := elems[depth]
+	if e.isIndex {
+		if n := sliceLen(c); e.index > n {
+			return nil, fmt.Errorf("%w: %s is out of range %d", ErrInvalidPath, formatPath(elems[:depth+1]), n)
+		}
+		if c == nil {
+			c = []interface{}{}
+		}
+		rv := reflect.ValueOf(c)
+		if rv.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("%w: %s is not a slice", ErrInvalidPath, formatPath(elems[:depth]))
+		}
+		if e.index == rv.Len() {
+			rv = reflect.Append(rv, reflect.Zero(rv.Type().Elem()))
+		}
+		ev, err := setPath(rv.Index(e.index).Interface(), elems, depth+1, v)
+		if err != nil {
+			return nil, err
+		}
+		if err = assignValue(rv.Index(e.index), ev); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPath, formatPath(elems[:depth+1]), err)
+		}
+		return rv.Interface(), nil
+	}
+
+	if c == nil {
+		c = M{}
+	}
+	switch m := c.(type) {
+	case M:
+		ev, err := setPath(m[e.key], elems, depth+1, v)
+		if err != nil {
+			return nil, err
+		}
+		m[e.key] = ev
+		return m, nil
+	case map[string]interface{}:
+		ev, err := setPath(m[e.key], elems, depth+1, v)
+		if err != nil {
+			return nil, err
+		}
+		m[e.key] = ev
+		return m, nil
+	}
+
+	rv := reflect.ValueOf(c)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("%w: %s is not a map", ErrInvalidPath, formatPath(elems[:depth]))
+	}
+	key := reflect.ValueOf(e.key).Convert(rv.Type().Key())
+	var child interface{}
+	if ev := rv.MapIndex(key); ev.IsValid() {
+		child = ev.Interface()
+	}
+	ev, err := setPath(child, elems, depth+1, v)
+	if err != nil {
+		return nil, err
+	}
+	nv := reflect.New(rv.Type().Elem()).Elem()
+	if err = assignValue(nv, ev); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidPath, formatPath(elems[:depth+1]), err)
+	}
+	rv.SetMapIndex(key, nv)
+	return c, nil
+}
+
+// sliceLen returns length of c if it's a slice, otherwise 0
+func sliceLen(c interface{}) int {
+	if rv := reflect.ValueOf(c); rv.Kind() == reflect.Slice {
+		return rv.Len()
+	}
+	return 0
+}
+
+func assignValue(dst reflect.Value, v interface{}) error {
+	if v == nil {
+		dst.Set(reflect.Zero(dst.Type()))
+		return nil
+	}
+	rv := reflect.ValueOf(v)
+	if !rv.Type().AssignableTo(dst.Type()) {
+		return fmt.Errorf("cannot assign %T to %v", v, dst.Type())
+	}
+	dst.Set(rv)
+	return nil
+}
+
+// Get returns value at path, e.g. user.addresses[0].city. The second result reports whether path exists
+func (m M) Get(path string) (interface{}, bool) {
+	elems, err := parsePath(path)
+	if err != nil {
+		return nil, false
+	}
+	return getPath(m, elems)
+}
+
+// Has reports whether path exists
+func (m M) Has(path string) bool {
+	_, ok := m.Get(path)
+	return ok
+}
+
+// GetString returns string at path
+func (m M) GetString(path string) (string, bool) {
+	v, ok := m.Get(path)
+	if !ok {
+		return "", false
+	}
+	s, err := ParseString(v)
+	return s, err == nil
+}
+
+// GetInt64 returns int64 at path
+func (m M) GetInt64(path string) (int64, bool) {
+	v, ok := m.Get(path)
+	if !ok {
+		return 0, false
+	}
+	i, err := ParseInt(v)
+	return i, err == nil
+}
+
+// GetInt returns int at path
+func (m M) GetInt(path string) (int, bool) {
+	i, ok := m.GetInt64(path)
+	return int(i), ok
+}
+
+// GetFloat64 returns float64 at path
+func (m M) GetFloat64(path string) (float64, bool) {
+	v, ok := m.Get(path)
+	if !ok {
+		return 0, false
+	}
+	f, err := ParseFloat(v)
+	return f, err == nil
+}
+
+// GetBool returns bool at path
+func (m M) GetBool(path string) (bool, bool) {
+	v, ok := m.Get(path)
+	if !ok {
+		return false, false
+	}
+	b, err := ParseBool(v)
+	return b, err == nil
+}
+
+// GetMap returns map at path
+func (m M) GetMap(path string) (M, bool) {
+	v, ok := m.Get(path)
+	if !ok {
+		return nil, false
+	}
+	switch mv := v.(type) {
+	case M:
+		return mv, true
+	case map[string]interface{}:
+		return M(mv), true
+	default:
+		return nil, false
+	}
+}
+
+// GetSlice returns slice at path
+func (m M) GetSlice(path string) ([]interface{}, bool) {
+	v, ok := m.Get(path)
+	if !ok {
+		return nil, false
+	}
+	if s, ok := v.([]interface{}); ok {
+		return s, true
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	s := make([]interface{}, rv.Len())
+	for i := range s {
+		s[i] = rv.Index(i).Interface()
+	}
+	return s, true
+}
+
+// Set sets value at path. Missing intermediate maps and slices are created. An index can be at most the length of slice,
+// which appends an element, e.g. a[1] can be set if a has 1 element, but a[2] cannot
+func (m M) Set(path string, v interface{}) error {
+	elems, err := parsePath(path)
+	if err != nil {
+		return err
+	}
+	if elems[0].isIndex {
+		return fmt.Errorf("%w: %s starts with index", ErrInvalidPath, path)
+	}
+	_, err = setPath(m, elems, 0, v)
+	return err
+}
+
+// Delete deletes value at path. Element deleted from slice shifts the following elements.
+// It reports whether path existed
+func (m M) Delete(path string) bool {
+	elems, err := parsePath(path)
+	if err != nil {
+		return false
+	}
+	return deletePath(m, elems)
+}
+
+func deletePath(m M, elems []pathElem) bool {
+	n := len(elems)
+	parent, ok := getPath(m, elems[:n-1])
+	if !ok {
+		return false
+	}
+	last := elems[n-1]
+	if !last.isIndex {
+		switch pm := parent.(type) {
+		case M:
+			_, ok = pm[last.key]
+			delete(pm, last.key)
+		case map[string]interface{}:
+			_, ok = pm[last.key]
+			delete(pm, last.key)
+		default:
+			rv := reflect.ValueOf(parent)
+			if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+				return false
+			}
+			key := reflect.ValueOf(last.key).Convert(rv.Type().Key())
+			ok = rv.MapIndex(key).IsValid()
+			rv.SetMapIndex(key, reflect.Value{})
+		}
+		return ok
+	}
+
+	rv := reflect.ValueOf(parent)
+	if rv.Kind() != reflect.Slice || last.index >= rv.Len() {
+		return false
+	}
+	s := reflect.MakeSlice(rv.Type(), 0, rv.Len()-1)
+	s = reflect.AppendSlice(s, rv.Slice(0, last.index))
+	s = reflect.AppendSlice(s, rv.Slice(last.index+1, rv.Len()))
+	_, err := setPath(m, elems[:n-1], 0, s.Interface())
+	return err == nil
+}
@@ -0,0 +1,118 @@
+package gox_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gopub/gox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestM_Get(t *testing.T) {
+	var m gox.M
+	err := json.Unmarshal([]byte(`{
+		"user": {
+			"name": "Tom",
+			"age": 20,
+			"vip": true,
+			"addresses": [{"city": "Beijing"}, {"city": "Shanghai"}],
+			"a.b": {"c": 1}
+		}
+	}`), &m)
+	require.NoError(t, err)
+
+	city, ok := m.GetString("user.addresses[1].city")
+	require.True(t, ok)
+	require.Equal(t, "Shanghai", city)
+
+	age, ok := m.GetInt("user.age")
+	require.True(t, ok)
+	require.Equal(t, 20, age)
+
+	vip, ok := m.GetBool("user.vip")
+	require.True(t, ok)
+	require.True(t, vip)
+
+	c, ok := m.GetInt64(`user["a.b"].c`)
+	require.True(t, ok)
+	require.Equal(t, int64(1), c)
+
+	addresses, ok := m.GetSlice("user.addresses")
+	require.True(t, ok)
+	require.Len(t, addresses, 2)
+
+	_, ok = m.Get("user.addresses[2].city")
+	require.False(t, ok)
+	_, ok = m.Get("user.name.first")
+	require.False(t, ok)
+	_, ok = m.GetInt("user.name")
+	require.False(t, ok)
+	require.False(t, m.Has("user.addresses[0].zip"))
+
+	typed := gox.M{"tags": []string{"a", "b"}, "users": []gox.M{{"name": "Tom"}}}
+	tag, ok := typed.GetString("tags[1]")
+	require.True(t, ok)
+	require.Equal(t, "b", tag)
+	name, ok := typed.GetString("users[0].name")
+	require.True(t, ok)
+	require.Equal(t, "Tom", name)
+}
+
+func TestM_Set(t *testing.T) {
+	m := gox.M{}
+	err := m.Set("user.addresses[1].city", "Shanghai")
+	require.True(t, errors.Is(err, gox.ErrInvalidPath))
+	require.NoError(t, m.Set("user.addresses[0].city", "Shanghai"))
+	require.Equal(t, gox.M{
+		"user": gox.M{
+			"addresses": []interface{}{gox.M{"city": "Shanghai"}},
+		},
+	}, m)
+
+	require.NoError(t, m.Set("user.addresses[1]", gox.M{"city": "Beijing"}))
+	require.NoError(t, m.Set("user.addresses[0]", gox.M{"city": "Hangzhou"}))
+	city, ok := m.GetString("user.addresses[0].city")
+	require.True(t, ok)
+	require.Equal(t, "Hangzhou", city)
+	city, ok = m.GetString("user.addresses[1].city")
+	require.True(t, ok)
+	require.Equal(t, "Beijing", city)
+
+	require.NoError(t, m.Set("user.name", "Tom"))
+	err = m.Set("user.name.first", "Tom")
+	require.True(t, errors.Is(err, gox.ErrInvalidPath))
+	require.Error(t, m.Set("[0]", 1))
+	require.Error(t, m.Set("user..name", 1))
+
+	typed := gox.M{"tags": []string{"a"}}
+	require.NoError(t, typed.Set("tags[1]", "b"))
+	require.Equal(t, []string{"a", "b"}, typed["tags"])
+	require.Error(t, typed.Set("tags[0]", 1))
+
+	err = m.Set("user.addresses[1000000000]", 1)
+	require.True(t, errors.Is(err, gox.ErrInvalidPath))
+	err = typed.Set("tags[3]", "d")
+	require.True(t, errors.Is(err, gox.ErrInvalidPath))
+	require.Len(t, typed["tags"], 2)
+	err = m.Set("list[1]", 1)
+	require.True(t, errors.Is(err, gox.ErrInvalidPath))
+	_, ok = m["list"]
+	require.False(t, ok)
+}
+
+func TestM_Delete(t *testing.T) {
+	m := gox.M{
+		"user": map[string]interface{}{
+			"name": "Tom",
+			"tags": []interface{}{"a", "b", "c"},
+		},
+	}
+	require.True(t, m.Delete("user.tags[1]"))
+	tags, _ := m.GetSlice("user.tags")
+	require.Equal(t, []interface{}{"a", "c"}, tags)
+	require.True(t, m.Delete("user.name"))
+	require.False(t, m.Has("user.name"))
+	require.False(t, m.Delete("user.name"))
+	require.False(t, m.Delete("user.tags[5]"))
+}
@@ -0,0 +1,480 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+func parseWAV(r io.ReaderAt, size int64, info *Info) error {
+	var byteRate uint32
+	off := int64(12)
+	for off+8 <= size {
+		h, err := readAt(r, off, 8)
+		if err != nil {
+			return err
+		}
+		n := int64(binary.LittleEndian.Uint32(h[4:8]))
+		switch string(h[:4]) {
+		case "fmt ":
+			// audio format(2) + channels(2) + sample rate(4) + byte rate(4)
+			b, err := readAt(r, off+8, 12)
+			if err != nil {
+				return err
+			}
+			byteRate = binary.LittleEndian.Uint32(b[8:12])
+		case "data":
+			if byteRate == 0 {
+				return errors.New("missing fmt chunk")
+			}
+			if n > size-off-8 {
+				// streaming writers may leave size unset
+				n = size - off - 8
+			}
+			info.Duration = time.Duration(float64(n) / float64(byteRate) * float64(time.Second))
+			return nil
+		}
+		off += 8 + n + n&1
+	}
+	return errors.New("missing data chunk")
+}
+
+// box is an ISO base media file box
+type box struct {
+	typ     string
+	dataOff int64
+	end     int64
+}
+
+func walkBoxes(r io.ReaderAt, off, end int64, f func(b *box) error) error {
+	for off+8 <= end {
+		h, err := readAt(r, off, 8)
+		if err != nil {
+			return err
+		}
+		b := &box{typ: string(h[4:8]), dataOff: off + 8}
+		n := int64(binary.BigEndian.Uint32(h[:4]))
+		switch n {
+		case 0:
+			n = end - off
+		case 1:
+			l, err := readAt(r, off+8, 8)
+			if err != nil {
+				return err
+			}
+			n = int64(binary.BigEndian.Uint64(l))
+			b.dataOff += 8
+		}
+		if n < b.dataOff-off || off+n > end {
+			return errors.New("invalid box size")
+		}
+		b.end = off + n
+		if err = f(b); err != nil {
+			return err
+		}
+		off = b.end
+	}
+	return nil
+}
+
+func parseMP4(r io.ReaderAt, size int64, info *Info) error {
+	found := false
+	err := walkBoxes(r, 0, size, func(b *box) error {
+		if b.typ != "moov" {
+			return nil
+		}
+		found = true
+		return walkBoxes(r, b.dataOff, b.end, func(b *box) error {
+			switch b.typ {
+			case "mvhd":
+				return parseMVHD(r, b, info)
+			case "trak":
+				if info.Width > 0 {
+					return nil
+				}
+				return walkBoxes(r, b.dataOff, b.end, func(b *box) error {
+					if b.typ == "tkhd" {
+						return parseTKHD(r, b, info)
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.New("missing moov box")
+	}
+	return nil
+}
+
+func parseMVHD(r io.ReaderAt, b *box, info *Info) error {
+	v, err := readAt(r, b.dataOff, 1)
+	if err != nil {
+		return err
+	}
+	var timescale uint32
+	var duration uint64
+	if v[0] == 1 {
+		// version/flags(4) + creation time(8) + modification time(8) + timescale(4) + duration(8)
+		d, err := readAt(r, b.dataOff+20, 12)
+		if err != nil {
+			return err
+		}
+		timescale = binary.BigEndian.Uint32(d[:4])
+		duration = binary.BigEndian.Uint64(d[4:])
+	} else {
+		// version/flags(4) + creation time(4) + modification time(4) + timescale(4) + duration(4)
+		d, err := readAt(r, b.dataOff+12, 8)
+		if err != nil {
+			return err
+		}
+		timescale = binary.BigEndian.Uint32(d[:4])
+		duration = uint64(binary.BigEndian.Uint32(d[4:]))
+	}
+	if timescale == 0 {
+		return errors.New("invalid timescale")
+	}
+	info.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+	return nil
+}
+
+func parseTKHD(r io.ReaderAt, b *box, info *Info) error {
+	v, err := readAt(r, b.dataOff, 1)
+	if err != nil {
+		return err
+	}
+	// width and height are 16.16 fixed-point numbers after matrix
+	off := b.dataOff + 76
+	if v[0] == 1 {
+		off = b.dataOff + 88
+	}
+	d, err := readAt(r, off, 8)
+	if err != nil {
+		return err
+	}
+	info.Width = int(binary.BigEndian.Uint32(d[:4]) >> 16)
+	info.Height = int(binary.BigEndian.Uint32(d[4:]) >> 16)
+	return nil
+}
+
+// EBML element ids used by WebM
+const (
+	ebmlIDSegment       = 0x18538067
+	ebmlIDInfo          = 0x1549a966
+	ebmlIDTimecodeScale = 0x2ad7b1
+	ebmlIDDuration      = 0x4489
+	ebmlIDTracks        = 0x1654ae6b
+	ebmlIDTrackEntry    = 0xae
+	ebmlIDVideo         = 0xe0
+	ebmlIDPixelWidth    = 0xb0
+	ebmlIDPixelHeight   = 0xba
+	ebmlIDCluster       = 0x1f43b675
+)
+
+type ebmlElement struct {
+	id      uint64
+	dataOff int64
+	// size is -1 if unknown
+	size int64
+}
+
+func readEBMLVint(r io.ReaderAt, off int64, keepMarker bool) (v uint64, n int, unknown bool, err error) {
+	b, err := readAt(r, off, 1)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	n = 1
+	for mask := byte(0x80); n <= 8 && b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if n > 8 {
+		return 0, 0, false, errors.New("invalid vint")
+	}
+	all := b
+	if n > 1 {
+		if all, err = readAt(r, off, n); err != nil {
+			return 0, 0, false, err
+		}
+	}
+	v = uint64(all[0])
+	if !keepMarker {
+		v &= uint64(0xff >> uint(n))
+	}
+	for _, c := range all[1:] {
+		v = v<<8 | uint64(c)
+	}
+	if !keepMarker {
+		unknown = v == 1<<uint(7*n)-1
+	}
+	return v, n, unknown, nil
+}
+
+func walkEBML(r io.ReaderAt, off, end int64, f func(e *ebmlElement) (bool, error)) error {
+	for off < end {
+		id, n, _, err := readEBMLVint(r, off, true)
+		if err != nil {
+			return err
+		}
+		size, m, unknown, err := readEBMLVint(r, off+int64(n), false)
+		if err != nil {
+			return err
+		}
+		e := &ebmlElement{id: id, dataOff: off + int64(n+m), size: int64(size)}
+		if unknown {
+			e.size = -1
+		} else if e.size < 0 || e.dataOff+e.size > end {
+			e.size = end - e.dataOff
+		}
+		cont, err := f(e)
+		if err != nil || !cont {
+			return err
+		}
+		if e.size < 0 {
+			// element of unknown size spans to the end of its parent
+			return nil
+		}
+		off = e.dataOff + e.size
+	}
+	return nil
+}
+
+func (e *ebmlElement) end(parentEnd int64) int64 {
+	if e.size < 0 {
+		return parentEnd
+	}
+	return e.dataOff + e.size
+}
+
+func readEBMLUint(r io.ReaderAt, e *ebmlElement) (uint64, error) {
+	if e.size < 0 || e.size > 8 {
+		return 0, errors.New("invalid uint size")
+	}
+	b, err := readAt(r, e.dataOff, int(e.size))
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func readEBMLFloat(r io.ReaderAt, e *ebmlElement) (float64, error) {
+	if e.size != 4 && e.size != 8 {
+		return 0, errors.New("invalid float size")
+	}
+	b, err := readAt(r, e.dataOff, int(e.size))
+	if err != nil {
+		return 0, err
+	}
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	default:
+		return 0, errors.New("invalid float size")
+	}
+}
+
+func parseWebM(r io.ReaderAt, size int64, info *Info) error {
+	timecodeScale := uint64(time.Millisecond)
+	var duration float64
+	err := walkEBML(r, 0, size, func(e *ebmlElement) (bool, error) {
+		if e.id != ebmlIDSegment {
+			return true, nil
+		}
+		segEnd := e.end(size)
+		return false, walkEBML(r, e.dataOff, segEnd, func(e *ebmlElement) (bool, error) {
+			var err error
+			switch e.id {
+			case ebmlIDInfo:
+				err = walkEBML(r, e.dataOff, e.end(segEnd), func(e *ebmlElement) (bool, error) {
+					var err error
+					switch e.id {
+					case ebmlIDTimecodeScale:
+						timecodeScale, err = readEBMLUint(r, e)
+					case ebmlIDDuration:
+						duration, err = readEBMLFloat(r, e)
+					}
+					return true, err
+				})
+			case ebmlIDTracks:
+				err = parseWebMTracks(r, e, segEnd, info)
+			case ebmlIDCluster:
+				// metadata precedes clusters
+				return false, nil
+			}
+			return true, err
+		})
+	})
+	if err != nil {
+		return err
+	}
+	info.Duration = time.Duration(duration * float64(timecodeScale))
+	return nil
+}
+
+func parseWebMTracks(r io.ReaderAt, tracks *ebmlElement, parentEnd int64, info *Info) error {
+	return walkEBML(r, tracks.dataOff, tracks.end(parentEnd), func(e *ebmlElement) (bool, error) {
+		if e.id != ebmlIDTrackEntry || info.Width > 0 {
+			return true, nil
+		}
+		return true, walkEBML(r, e.dataOff, e.end(parentEnd), func(e *ebmlElement) (bool, error) {
+			if e.id != ebmlIDVideo {
+				return true, nil
+			}
+			return true, walkEBML(r, e.dataOff, e.end(parentEnd), func(e *ebmlElement) (bool, error) {
+				var err error
+				var v uint64
+				switch e.id {
+				case ebmlIDPixelWidth:
+					v, err = readEBMLUint(r, e)
+					info.Width = int(v)
+				case ebmlIDPixelHeight:
+					v, err = readEBMLUint(r, e)
+					info.Height = int(v)
+				}
+				return true, err
+			})
+		})
+	})
+}
+
+var mp3Bitrates = [2][3][15]int{
+	// MPEG-1: layer I, II, III
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	// MPEG-2 and MPEG-2.5: layer I, II, III
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+var mp3SampleRates = [4][3]int{
+	{11025, 12000, 8000},  // MPEG-2.5
+	{},                    // reserved
+	{22050, 24000, 16000}, // MPEG-2
+	{44100, 48000, 32000}, // MPEG-1
+}
+
+type mp3FrameHeader struct {
+	mpeg1           bool
+	mono            bool
+	bitrate         int // kbps
+	sampleRate      int
+	samplesPerFrame int
+}
+
+func parseMP3FrameHeader(b []byte) (*mp3FrameHeader, bool) {
+	if len(b) < 4 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return nil, false
+	}
+	version := (b[1] >> 3) & 3
+	layer := (b[1] >> 1) & 3
+	bitrateIndex := b[2] >> 4
+	sampleRateIndex := (b[2] >> 2) & 3
+	if version == 1 || layer == 0 || bitrateIndex == 0 || bitrateIndex == 15 || sampleRateIndex == 3 {
+		return nil, false
+	}
+	h := &mp3FrameHeader{
+		mpeg1:      version == 3,
+		mono:       b[3]>>6 == 3,
+		sampleRate: mp3SampleRates[version][sampleRateIndex],
+	}
+	l := 3 - int(layer) // 0: layer I, 1: layer II, 2: layer III
+	v := 1
+	if h.mpeg1 {
+		v = 0
+	}
+	h.bitrate = mp3Bitrates[v][l][bitrateIndex]
+	switch {
+	case l == 0:
+		h.samplesPerFrame = 384
+	case l == 2 && !h.mpeg1:
+		h.samplesPerFrame = 576
+	default:
+		h.samplesPerFrame = 1152
+	}
+	return h, true
+}
+
+func parseMP3(r io.ReaderAt, size int64, info *Info) error {
+	off := int64(0)
+	if b, err := readAt(r, 0, 10); err == nil && string(b[:3]) == "ID3" {
+		// ID3v2 tag size is a syncsafe integer
+		n := int64(b[6])<<21 | int64(b[7])<<14 | int64(b[8])<<7 | int64(b[9])
+		off = 10 + n
+		if b[5]&0x10 != 0 {
+			off += 10
+		}
+	}
+
+	if off >= size {
+		return errors.New("missing frame")
+	}
+
+	const maxScan = 64 << 10
+	end := off + maxScan
+	if end > size {
+		end = size
+	}
+	buf := make([]byte, end-off)
+	if _, err := r.ReadAt(buf, off); err != nil && err != io.EOF {
+		return err
+	}
+	var h *mp3FrameHeader
+	for i := 0; i+4 <= len(buf); i++ {
+		if fh, ok := parseMP3FrameHeader(buf[i:]); ok {
+			h = fh
+			buf = buf[i:]
+			off += int64(i)
+			break
+		}
+	}
+	if h == nil {
+		return errors.New("missing frame")
+	}
+
+	// VBR files carry frame count in Xing/Info or VBRI header
+	sideInfo := 32
+	switch {
+	case h.mpeg1 && h.mono:
+		sideInfo = 17
+	case !h.mpeg1 && !h.mono:
+		sideInfo = 17
+	case !h.mpeg1 && h.mono:
+		sideInfo = 9
+	}
+	var frames uint32
+	if x := 4 + sideInfo; len(buf) >= x+12 && (string(buf[x:x+4]) == "Xing" || string(buf[x:x+4]) == "Info") {
+		if binary.BigEndian.Uint32(buf[x+4:x+8])&1 != 0 {
+			frames = binary.BigEndian.Uint32(buf[x+8 : x+12])
+		}
+	} else if len(buf) >= 36+18 && string(buf[36:40]) == "VBRI" {
+		frames = binary.BigEndian.Uint32(buf[36+14 : 36+18])
+	}
+	if frames > 0 {
+		info.Duration = time.Duration(float64(frames) * float64(h.samplesPerFrame) / float64(h.sampleRate) * float64(time.Second))
+		return nil
+	}
+
+	audioSize := size - off
+	if b, err := readAt(r, size-128, 3); err == nil && string(b) == "TAG" {
+		audioSize -= 128
+	}
+	info.Duration = time.Duration(float64(audioSize*8) / float64(h.bitrate*1000) * float64(time.Second))
+	return nil
+}
@@ -0,0 +1,112 @@
+package media
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+func parsePNG(r io.ReaderAt, size int64, info *Info) error {
+	// signature(8) + chunk length(4) + "IHDR"(4) + width(4) + height(4)
+	b, err := readAt(r, 8, 16)
+	if err != nil {
+		return err
+	}
+	if string(b[4:8]) != "IHDR" {
+		return errors.New("missing IHDR")
+	}
+	info.Width = int(binary.BigEndian.Uint32(b[8:12]))
+	info.Height = int(binary.BigEndian.Uint32(b[12:16]))
+	return nil
+}
+
+func parseGIF(r io.ReaderAt, size int64, info *Info) error {
+	b, err := readAt(r, 6, 4)
+	if err != nil {
+		return err
+	}
+	info.Width = int(binary.LittleEndian.Uint16(b[0:2]))
+	info.Height = int(binary.LittleEndian.Uint16(b[2:4]))
+	return nil
+}
+
+func parseJPEG(r io.ReaderAt, size int64, info *Info) error {
+	off := int64(2)
+	for off+4 <= size {
+		b, err := readAt(r, off, 4)
+		if err != nil {
+			return err
+		}
+		if b[0] != 0xff {
+			return errors.New("invalid marker")
+		}
+		marker := b[1]
+		switch {
+		case marker == 0xff:
+			// fill byte
+			off++
+			continue
+		case marker == 0x01 || (marker >= 0xd0 && marker <= 0xd7):
+			// markers without length
+			off += 2
+			continue
+		case marker == 0xd9 || marker == 0xda:
+			return errors.New("missing SOF")
+		}
+		length := int64(binary.BigEndian.Uint16(b[2:4]))
+		if length < 2 {
+			return errors.New("invalid segment length")
+		}
+		if marker >= 0xc0 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc {
+			// length(2) + precision(1) + height(2) + width(2)
+			sof, err := readAt(r, off+4, 5)
+			if err != nil {
+				return err
+			}
+			info.Height = int(binary.BigEndian.Uint16(sof[1:3]))
+			info.Width = int(binary.BigEndian.Uint16(sof[3:5]))
+			return nil
+		}
+		off += 2 + length
+	}
+	return errors.New("missing SOF")
+}
+
+func parseWebP(r io.ReaderAt, size int64, info *Info) error {
+	// RIFF header(12) + chunk fourcc(4) + chunk size(4) + chunk data
+	b, err := readAt(r, 12, 8)
+	if err != nil {
+		return err
+	}
+	n := 10
+	if string(b[:4]) == "VP8L" {
+		n = 5
+	}
+	d, err := readAt(r, 20, n)
+	if err != nil {
+		return err
+	}
+	switch string(b[:4]) {
+	case "VP8 ":
+		// frame tag(3) + start code(3) + width(2) + height(2)
+		if d[3] != 0x9d || d[4] != 0x01 || d[5] != 0x2a {
+			return errors.New("invalid VP8 start code")
+		}
+		info.Width = int(binary.LittleEndian.Uint16(d[6:8]) & 0x3fff)
+		info.Height = int(binary.LittleEndian.Uint16(d[8:10]) & 0x3fff)
+	case "VP8L":
+		if d[0] != 0x2f {
+			return errors.New("invalid VP8L signature")
+		}
+		v := binary.LittleEndian.Uint32(d[1:5])
+		info.Width = int(v&0x3fff) + 1
+		info.Height = int((v>>14)&0x3fff) + 1
+	case "VP8X":
+		// flags(4) + canvas width-1(3) + canvas height-1(3)
+		info.Width = int(uint32(d[4])|uint32(d[5])<<8|uint32(d[6])<<16) + 1
+		info.Height = int(uint32(d[7])|uint32(d[8])<<8|uint32(d[9])<<16) + 1
+	default:
+		return errors.New("unknown WebP chunk")
+	}
+	return nil
+}
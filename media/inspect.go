@@ -0,0 +1,235 @@
+// Package media inspects media data to detect format, dimensions and duration, and makes thumbnails
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gopub/gox"
+)
+
+const ErrUnsupportedFormat gox.ErrorString = "unsupported format"
+
+// Info is metadata of media data
+type Info struct {
+	MIMEType string
+	// Format is the short name of format, e.g. png, jpeg, mp4
+	Format   string
+	Width    int
+	Height   int
+	Duration time.Duration
+	Size     int64
+}
+
+func (i *Info) IsImage() bool {
+	return strings.HasPrefix(i.MIMEType, "image/")
+}
+
+func (i *Info) IsVideo() bool {
+	return strings.HasPrefix(i.MIMEType, "video/")
+}
+
+func (i *Info) IsAudio() bool {
+	return strings.HasPrefix(i.MIMEType, "audio/")
+}
+
+// headerSize is the number of bytes used to detect format
+const headerSize = 512
+
+type format struct {
+	name     string
+	mimeType string
+	match    func(h []byte) bool
+	parse    func(r io.ReaderAt, size int64, info *Info) error
+}
+
+var formats = []*format{
+	{"png", "image/png", matchPrefix("\x89PNG\r\n\x1a\n"), parsePNG},
+	{"jpeg", "image/jpeg", matchPrefix("\xff\xd8\xff"), parseJPEG},
+	{"gif", "image/gif", func(h []byte) bool {
+		return bytes.HasPrefix(h, []byte("GIF87a")) || bytes.HasPrefix(h, []byte("GIF89a"))
+	}, parseGIF},
+	{"webp", "image/webp", matchRIFF("WEBP"), parseWebP},
+	{"wav", "audio/wav", matchRIFF("WAVE"), parseWAV},
+	{"m4a", "audio/mp4", func(h []byte) bool {
+		return matchFtyp(h) && (bytes.Equal(h[8:12], []byte("M4A ")) || bytes.Equal(h[8:12], []byte("M4B ")))
+	}, parseMP4},
+	{"mp4", "video/mp4", matchFtyp, parseMP4},
+	{"webm", "video/webm", matchPrefix("\x1a\x45\xdf\xa3"), parseWebM},
+	{"mp3", "audio/mpeg", matchMP3, parseMP3},
+}
+
+func matchPrefix(prefix string) func(h []byte) bool {
+	return func(h []byte) bool {
+		return bytes.HasPrefix(h, []byte(prefix))
+	}
+}
+
+func matchRIFF(typ string) func(h []byte) bool {
+	return func(h []byte) bool {
+		return len(h) >= 12 && bytes.Equal(h[:4], []byte("RIFF")) && bytes.Equal(h[8:12], []byte(typ))
+	}
+}
+
+func matchFtyp(h []byte) bool {
+	return len(h) >= 12 && bytes.Equal(h[4:8], []byte("ftyp"))
+}
+
+func matchMP3(h []byte) bool {
+	if bytes.HasPrefix(h, []byte("ID3")) {
+		return true
+	}
+	_, ok := parseMP3FrameHeader(h)
+	return ok
+}
+
+// InspectBytes inspects data
+func InspectBytes(data []byte) (*Info, error) {
+	return InspectReaderAt(bytes.NewReader(data), int64(len(data)))
+}
+
+// Inspect inspects data read from r. If r is io.ReaderAt and io.Seeker, e.g. *os.File, only necessary parts
+// from the current offset are read and the offset is kept, otherwise all data is read into memory.
+func Inspect(r io.Reader) (*Info, error) {
+	if rs, ok := r.(interface {
+		io.ReaderAt
+		io.Seeker
+	}); ok {
+		off, err := rs.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, fmt.Errorf("seek: %w", err)
+		}
+		size, err := rs.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, fmt.Errorf("seek: %w", err)
+		}
+		if _, err = rs.Seek(off, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("seek: %w", err)
+		}
+		return InspectReaderAt(io.NewSectionReader(rs, off, size-off), size-off)
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read: %w", err)
+	}
+	return InspectBytes(data)
+}
+
+// InspectReaderAt inspects data of size read from r
+func InspectReaderAt(r io.ReaderAt, size int64) (*Info, error) {
+	h := make([]byte, headerSize)
+	n, err := r.ReadAt(h, 0)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+	h = h[:n]
+	if n == 0 {
+		return nil, errors.New("empty data")
+	}
+
+	info := &Info{Size: size}
+	for _, f := range formats {
+		if !f.match(h) {
+			continue
+		}
+		info.Format = f.name
+		info.MIMEType = f.mimeType
+		if err = f.parse(r, size, info); err != nil {
+			return nil, fmt.Errorf("parse %s: %w", f.name, err)
+		}
+		return info, nil
+	}
+
+	info.MIMEType = http.DetectContentType(h)
+	if i := strings.Index(info.MIMEType, ";"); i > 0 {
+		info.MIMEType = info.MIMEType[:i]
+	}
+	if i := strings.LastIndex(info.MIMEType, "/"); i > 0 {
+		info.Format = strings.TrimPrefix(info.MIMEType[i+1:], "x-")
+	}
+	return info, nil
+}
+
+// PopulateImage fills Width, Height, Format and Size of img by inspecting img.Data
+func PopulateImage(img *gox.Image) error {
+	info, err := InspectBytes(img.Data)
+	if err != nil {
+		return err
+	}
+	if !info.IsImage() || info.Width == 0 {
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, info.MIMEType)
+	}
+	img.Width = info.Width
+	img.Height = info.Height
+	img.Format = info.Format
+	img.Size = int(info.Size)
+	return nil
+}
+
+// PopulateVideo fills Format, Length in seconds and Size of v by inspecting v.Data
+func PopulateVideo(v *gox.Video) error {
+	info, err := InspectBytes(v.Data)
+	if err != nil {
+		return err
+	}
+	if !info.IsVideo() {
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, info.MIMEType)
+	}
+	v.Format = info.Format
+	v.Length = durationToLength(info.Duration)
+	v.Size = int(info.Size)
+	return nil
+}
+
+// PopulateAudio fills Format, Length in seconds and Size of a by inspecting a.Data
+func PopulateAudio(a *gox.Audio) error {
+	info, err := InspectBytes(a.Data)
+	if err != nil {
+		return err
+	}
+	if !info.IsAudio() {
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, info.MIMEType)
+	}
+	a.Format = info.Format
+	a.Length = durationToLength(info.Duration)
+	a.Size = int(info.Size)
+	return nil
+}
+
+// PopulateFile fills Format and Size of f by inspecting f.Data
+func PopulateFile(f *gox.File) error {
+	info, err := InspectBytes(f.Data)
+	if err != nil {
+		return err
+	}
+	f.Format = info.Format
+	f.Size = int(info.Size)
+	return nil
+}
+
+func durationToLength(d time.Duration) int {
+	return int((d + time.Second/2) / time.Second)
+}
+
+// readAt reads n bytes at off
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	if n < 0 || off < 0 {
+		return nil, errors.New("invalid range")
+	}
+	b := make([]byte, n)
+	// ReaderAt may return io.EOF along with a full read at the end of data
+	if m, err := r.ReadAt(b, off); err != nil && !(err == io.EOF && m == n) {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,195 @@
+package media_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gopub/gox"
+	"github.com/gopub/gox/media"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPopulateImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 30, 20))
+	encoders := map[string]func(b *bytes.Buffer) error{
+		"png": func(b *bytes.Buffer) error { return png.Encode(b, img) },
+		"jpeg": func(b *bytes.Buffer) error {
+			return jpeg.Encode(b, img, nil)
+		},
+		"gif": func(b *bytes.Buffer) error { return gif.Encode(b, img, nil) },
+	}
+	for format, encode := range encoders {
+		t.Run(format, func(t *testing.T) {
+			var b bytes.Buffer
+			require.NoError(t, encode(&b))
+			v := &gox.Image{Data: b.Bytes()}
+			require.NoError(t, media.PopulateImage(v))
+			require.Equal(t, 30, v.Width)
+			require.Equal(t, 20, v.Height)
+			require.Equal(t, format, v.Format)
+			require.Equal(t, b.Len(), v.Size)
+		})
+	}
+
+	t.Run("webp", func(t *testing.T) {
+		data := riff("WEBP", chunk("VP8L", append([]byte{0x2f}, le32(uint32(30-1)|uint32(20-1)<<14)...)))
+		v := &gox.Image{Data: data}
+		require.NoError(t, media.PopulateImage(v))
+		require.Equal(t, 30, v.Width)
+		require.Equal(t, 20, v.Height)
+		require.Equal(t, "webp", v.Format)
+	})
+
+	t.Run("NotImage", func(t *testing.T) {
+		require.Error(t, media.PopulateImage(&gox.Image{Data: []byte("hello")}))
+	})
+}
+
+func TestPopulateAudio(t *testing.T) {
+	t.Run("wav", func(t *testing.T) {
+		fmtChunk := make([]byte, 16)
+		binary.LittleEndian.PutUint16(fmtChunk[0:], 1)
+		binary.LittleEndian.PutUint16(fmtChunk[2:], 1)
+		binary.LittleEndian.PutUint32(fmtChunk[4:], 8000)
+		binary.LittleEndian.PutUint32(fmtChunk[8:], 16000)
+		data := riff("WAVE", append(chunk("fmt ", fmtChunk), chunk("data", make([]byte, 48000))...))
+		a := &gox.Audio{Data: data}
+		require.NoError(t, media.PopulateAudio(a))
+		require.Equal(t, "wav", a.Format)
+		require.Equal(t, 3, a.Length)
+	})
+
+	t.Run("mp3", func(t *testing.T) {
+		// MPEG-1 layer III, 128kbps, 44.1kHz, 1 second of data after ID3v2 tag
+		data := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x0a"), make([]byte, 10)...)
+		frames := make([]byte, 16000)
+		copy(frames, []byte{0xff, 0xfb, 0x90, 0x00})
+		data = append(data, frames...)
+		info, err := media.InspectBytes(data)
+		require.NoError(t, err)
+		require.Equal(t, "audio/mpeg", info.MIMEType)
+		require.Equal(t, time.Second, info.Duration)
+	})
+}
+
+func TestPopulateVideo(t *testing.T) {
+	t.Run("mp4", func(t *testing.T) {
+		mvhd := make([]byte, 100)
+		binary.BigEndian.PutUint32(mvhd[12:], 1000)
+		binary.BigEndian.PutUint32(mvhd[16:], 12500)
+		tkhd := make([]byte, 84)
+		binary.BigEndian.PutUint32(tkhd[76:], 640<<16)
+		binary.BigEndian.PutUint32(tkhd[80:], 360<<16)
+		data := append(mp4Box("ftyp", []byte("isom\x00\x00\x02\x00isom")),
+			mp4Box("moov", append(mp4Box("mvhd", mvhd), mp4Box("trak", mp4Box("tkhd", tkhd))...))...)
+		info, err := media.InspectBytes(data)
+		require.NoError(t, err)
+		require.Equal(t, "video/mp4", info.MIMEType)
+		require.Equal(t, 640, info.Width)
+		require.Equal(t, 360, info.Height)
+		require.Equal(t, 12500*time.Millisecond, info.Duration)
+
+		v := &gox.Video{Data: data}
+		require.NoError(t, media.PopulateVideo(v))
+		require.Equal(t, "mp4", v.Format)
+		require.Equal(t, 13, v.Length)
+	})
+
+	t.Run("webm", func(t *testing.T) {
+		duration := make([]byte, 8)
+		binary.BigEndian.PutUint64(duration, math.Float64bits(2500))
+		info := append(ebml([]byte{0x2a, 0xd7, 0xb1}, []byte{0x0f, 0x42, 0x40}), ebml([]byte{0x44, 0x89}, duration)...)
+		video := append(ebml([]byte{0xb0}, []byte{0x02, 0x80}), ebml([]byte{0xba}, []byte{0x01, 0x68})...)
+		tracks := ebml([]byte{0xae}, ebml([]byte{0xe0}, video))
+		segment := append(ebml([]byte{0x15, 0x49, 0xa9, 0x66}, info), ebml([]byte{0x16, 0x54, 0xae, 0x6b}, tracks)...)
+		data := append(ebml([]byte{0x1a, 0x45, 0xdf, 0xa3}, []byte{0x42, 0x82, 0x84, 'w', 'e', 'b', 'm'}),
+			ebml([]byte{0x18, 0x53, 0x80, 0x67}, segment)...)
+		v, err := media.InspectBytes(data)
+		require.NoError(t, err)
+		require.Equal(t, "video/webm", v.MIMEType)
+		require.Equal(t, 640, v.Width)
+		require.Equal(t, 360, v.Height)
+		require.Equal(t, 2500*time.Millisecond, v.Duration)
+	})
+}
+
+func TestInspect(t *testing.T) {
+	info, err := media.Inspect(bytes.NewBufferString("%PDF-1.4\n"))
+	require.NoError(t, err)
+	require.Equal(t, "application/pdf", info.MIMEType)
+	require.Equal(t, "pdf", info.Format)
+
+	t.Run("KeepOffset", func(t *testing.T) {
+		r := bytes.NewReader([]byte("junk%PDF-1.4\n"))
+		_, err := r.Seek(4, io.SeekStart)
+		require.NoError(t, err)
+		info, err := media.Inspect(r)
+		require.NoError(t, err)
+		require.Equal(t, "pdf", info.Format)
+		off, err := r.Seek(0, io.SeekCurrent)
+		require.NoError(t, err)
+		require.Equal(t, int64(4), off)
+	})
+
+	t.Run("HostileWebM", func(t *testing.T) {
+		data, err := hex.DecodeString("1a45dfa38018538067ff1549a966ff4489ff")
+		require.NoError(t, err)
+		require.NotPanics(t, func() {
+			_, err = media.InspectBytes(data)
+		})
+		require.Error(t, err)
+	})
+
+	t.Run("TruncatedMP3", func(t *testing.T) {
+		data := []byte{'I', 'D', '3', 3, 0, 0, 0x7f, 0x7f, 0x7f, 0x7f, 0xff, 0xfb, 0x90, 0x00}
+		require.NotPanics(t, func() {
+			_, err = media.InspectBytes(data)
+		})
+		require.Error(t, err)
+	})
+}
+
+func le32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+func chunk(id string, data []byte) []byte {
+	b := append([]byte(id), le32(uint32(len(data)))...)
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func riff(typ string, chunks []byte) []byte {
+	b := append([]byte("RIFF"), le32(uint32(4+len(chunks)))...)
+	return append(append(b, typ...), chunks...)
+}
+
+func mp4Box(typ string, data []byte) []byte {
+	b := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint32(b, uint32(8+len(data)))
+	copy(b[4:], typ)
+	return append(b, data...)
+}
+
+func ebml(id []byte, data []byte) []byte {
+	// 8-byte size vint
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(data)))
+	size[0] = 0x01
+	b := append(append([]byte{}, id...), size...)
+	return append(b, data...)
+}
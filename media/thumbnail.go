@@ -0,0 +1,283 @@
+package media
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+
+	// register decoders
+	_ "image/gif"
+
+	"github.com/gopub/gox"
+)
+
+// Filter is a resampling filter
+type Filter struct {
+	// Support is the radius of kernel
+	Support float64
+	Kernel  func(x float64) float64
+}
+
+var (
+	// Bilinear is fast with acceptable quality
+	Bilinear = &Filter{
+		Support: 1,
+		Kernel: func(x float64) float64 {
+			x = math.Abs(x)
+			if x < 1 {
+				return 1 - x
+			}
+			return 0
+		},
+	}
+
+	// CatmullRom is sharp cubic filter
+	CatmullRom = &Filter{
+		Support: 2,
+		Kernel: func(x float64) float64 {
+			x = math.Abs(x)
+			switch {
+			case x < 1:
+				return (1.5*x-2.5)*x*x + 1
+			case x < 2:
+				return ((-0.5*x+2.5)*x-4)*x + 2
+			default:
+				return 0
+			}
+		},
+	}
+
+	// Lanczos is high quality filter with 3 lobes
+	Lanczos = &Filter{
+		Support: 3,
+		Kernel: func(x float64) float64 {
+			x = math.Abs(x)
+			if x >= 3 {
+				return 0
+			}
+			return sinc(x) * sinc(x/3)
+		},
+	}
+)
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	x *= math.Pi
+	return math.Sin(x) / x
+}
+
+// ErrImageTooLarge is returned if source image has more pixels than allowed
+const ErrImageTooLarge gox.ErrorString = "image too large"
+
+// DefaultMaxThumbnailPixels is the default limit of pixels of source image, which bounds memory used by decoding
+const DefaultMaxThumbnailPixels = 50_000_000
+
+// ThumbnailOptions customizes thumbnail generation
+type ThumbnailOptions struct {
+	// Format is png or jpeg. Default is jpeg for jpeg images, otherwise png
+	Format string
+	// Quality is jpeg quality in [1, 100], default is 85
+	Quality int
+	// Filter is Lanczos by default
+	Filter *Filter
+	// MaxPixels limits width*height of source image, default is DefaultMaxThumbnailPixels
+	MaxPixels int
+}
+
+// MakeThumbnail decodes img.Data, scales it down to fit in maxWidth x maxHeight with aspect ratio kept,
+// and returns a new image with encoded data. Images smaller than the box are not scaled up.
+func MakeThumbnail(img *gox.Image, maxWidth, maxHeight int, opts *ThumbnailOptions) (*gox.Image, error) {
+	if maxWidth <= 0 || maxHeight <= 0 {
+		return nil, fmt.Errorf("invalid size %dx%d", maxWidth, maxHeight)
+	}
+	var o ThumbnailOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxPixels <= 0 {
+		o.MaxPixels = DefaultMaxThumbnailPixels
+	}
+	// check dimensions in header before allocating pixels
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(img.Data))
+	if err != nil {
+		return nil, fmt.Errorf("decode config: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, fmt.Errorf("invalid image size %dx%d", cfg.Width, cfg.Height)
+	}
+	if cfg.Width > o.MaxPixels/cfg.Height {
+		return nil, fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+	src, srcFormat, err := image.Decode(bytes.NewReader(img.Data))
+	if err != nil {
+		return nil, fmt.Errorf("decode: %w", err)
+	}
+
+	if o.Format == "" {
+		o.Format = "png"
+		if srcFormat == "jpeg" {
+			o.Format = "jpeg"
+		}
+	}
+	if o.Quality <= 0 {
+		o.Quality = 85
+	}
+	if o.Filter == nil {
+		o.Filter = Lanczos
+	}
+
+	w, h := fitSize(src.Bounds().Dx(), src.Bounds().Dy(), maxWidth, maxHeight)
+	dst := Resize(src, w, h, o.Filter)
+
+	var b bytes.Buffer
+	switch o.Format {
+	case "png":
+		err = png.Encode(&b, dst)
+	case "jpeg", "jpg":
+		o.Format = "jpeg"
+		err = jpeg.Encode(&b, dst, &jpeg.Options{Quality: o.Quality})
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedFormat, o.Format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode: %w", err)
+	}
+	return &gox.Image{
+		Width:  w,
+		Height: h,
+		Format: o.Format,
+		Size:   b.Len(),
+		Name:   img.Name,
+		Data:   b.Bytes(),
+	}, nil
+}
+
+func fitSize(w, h, maxWidth, maxHeight int) (int, int) {
+	if w <= maxWidth && h <= maxHeight {
+		return w, h
+	}
+	if w*maxHeight > h*maxWidth {
+		h = int(math.Max(1, math.Round(float64(h)*float64(maxWidth)/float64(w))))
+		return maxWidth, h
+	}
+	w = int(math.Max(1, math.Round(float64(w)*float64(maxHeight)/float64(h))))
+	return w, maxHeight
+}
+
+// Resize resamples src to width x height with filter
+func Resize(src image.Image, width, height int, filter *Filter) *image.RGBA {
+	sb := src.Bounds()
+	rgba, ok := src.(*image.RGBA)
+	if !ok || sb.Min != (image.Point{}) {
+		rgba = image.NewRGBA(image.Rect(0, 0, sb.Dx(), sb.Dy()))
+		draw.Draw(rgba, rgba.Bounds(), src, sb.Min, draw.Src)
+	}
+	sw, sh := sb.Dx(), sb.Dy()
+
+	// horizontal pass: sw x sh -> width x sh
+	xw := makeWeights(sw, width, filter)
+	tmp := make([]float64, width*sh*4)
+	for y := 0; y < sh; y++ {
+		row := rgba.Pix[y*rgba.Stride:]
+		for x, ws := range xw {
+			var r, g, b, a float64
+			for _, w := range ws {
+				p := row[w.index*4:]
+				r += float64(p[0]) * w.weight
+				g += float64(p[1]) * w.weight
+				b += float64(p[2]) * w.weight
+				a += float64(p[3]) * w.weight
+			}
+			t := tmp[(y*width+x)*4:]
+			t[0], t[1], t[2], t[3] = r, g, b, a
+		}
+	}
+
+	// vertical pass: width x sh -> width x height
+	yw := makeWeights(sh, height, filter)
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, ws := range yw {
+		for x := 0; x < width; x++ {
+			var r, g, b, a float64
+			for _, w := range ws {
+				t := tmp[(w.index*width+x)*4:]
+				r += t[0] * w.weight
+				g += t[1] * w.weight
+				b += t[2] * w.weight
+				a += t[3] * w.weight
+			}
+			p := dst.Pix[y*dst.Stride+x*4:]
+			p[3] = clampUint8(a)
+			// premultiplied color must not exceed alpha
+			p[0] = minUint8(clampUint8(r), p[3])
+			p[1] = minUint8(clampUint8(g), p[3])
+			p[2] = minUint8(clampUint8(b), p[3])
+		}
+	}
+	return dst
+}
+
+type weight struct {
+	index  int
+	weight float64
+}
+
+// makeWeights computes contributions of source pixels for each destination pixel
+func makeWeights(srcSize, dstSize int, filter *Filter) [][]weight {
+	scale := float64(srcSize) / float64(dstSize)
+	filterScale := math.Max(scale, 1)
+	support := filter.Support * filterScale
+	res := make([][]weight, dstSize)
+	for i := range res {
+		center := (float64(i)+0.5)*scale - 0.5
+		left := int(math.Ceil(center - support))
+		right := int(math.Floor(center + support))
+		var sum float64
+		ws := make([]weight, 0, right-left+1)
+		for j := left; j <= right; j++ {
+			w := filter.Kernel((float64(j) - center) / filterScale)
+			if w == 0 {
+				continue
+			}
+			k := j
+			if k < 0 {
+				k = 0
+			} else if k >= srcSize {
+				k = srcSize - 1
+			}
+			ws = append(ws, weight{index: k, weight: w})
+			sum += w
+		}
+		if sum != 0 {
+			for j := range ws {
+				ws[j].weight /= sum
+			}
+		}
+		res[i] = ws
+	}
+	return res
+}
+
+func clampUint8(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	default:
+		return uint8(v + 0.5)
+	}
+}
+
+func minUint8(a, b uint8) uint8 {
+	if a < b {
+		return a
+	}
+	return b
+}
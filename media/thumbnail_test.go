@@ -0,0 +1,93 @@
+package media_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/gopub/gox"
+	"github.com/gopub/gox/media"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeThumbnail(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 400, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 400; x++ {
+			src.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var b bytes.Buffer
+	require.NoError(t, png.Encode(&b, src))
+
+	t.Run("png", func(t *testing.T) {
+		thumb, err := media.MakeThumbnail(&gox.Image{Data: b.Bytes(), Name: "a.png"}, 100, 100, nil)
+		require.NoError(t, err)
+		require.Equal(t, 100, thumb.Width)
+		require.Equal(t, 50, thumb.Height)
+		require.Equal(t, "png", thumb.Format)
+		require.Equal(t, len(thumb.Data), thumb.Size)
+		require.Equal(t, "a.png", thumb.Name)
+
+		img, err := png.Decode(bytes.NewReader(thumb.Data))
+		require.NoError(t, err)
+		require.Equal(t, image.Rect(0, 0, 100, 50), img.Bounds())
+		r, g, _, a := img.At(50, 25).RGBA()
+		require.InDelta(t, 202, r>>8, 4)
+		require.InDelta(t, 102, g>>8, 4)
+		require.Equal(t, uint32(0xffff), a)
+	})
+
+	t.Run("jpeg", func(t *testing.T) {
+		thumb, err := media.MakeThumbnail(&gox.Image{Data: b.Bytes()}, 80, 80, &media.ThumbnailOptions{
+			Format: "jpeg",
+			Filter: media.CatmullRom,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 80, thumb.Width)
+		require.Equal(t, 40, thumb.Height)
+		require.Equal(t, "jpeg", thumb.Format)
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(thumb.Data))
+		require.NoError(t, err)
+		require.Equal(t, 80, cfg.Width)
+	})
+
+	t.Run("gif", func(t *testing.T) {
+		var gb bytes.Buffer
+		require.NoError(t, gif.Encode(&gb, src, nil))
+		thumb, err := media.MakeThumbnail(&gox.Image{Data: gb.Bytes()}, 50, 400, nil)
+		require.NoError(t, err)
+		require.Equal(t, 50, thumb.Width)
+		require.Equal(t, 25, thumb.Height)
+		require.Equal(t, "png", thumb.Format)
+	})
+
+	t.Run("NoUpscale", func(t *testing.T) {
+		thumb, err := media.MakeThumbnail(&gox.Image{Data: b.Bytes()}, 1000, 1000, nil)
+		require.NoError(t, err)
+		require.Equal(t, 400, thumb.Width)
+		require.Equal(t, 200, thumb.Height)
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		_, err := media.MakeThumbnail(&gox.Image{Data: b.Bytes()}, 100, 100, &media.ThumbnailOptions{MaxPixels: 400*200 - 1})
+		require.True(t, errors.Is(err, media.ErrImageTooLarge))
+
+		// header of 100000x100000 png without pixel data
+		var hb bytes.Buffer
+		require.NoError(t, png.Encode(&hb, image.NewGray(image.Rect(0, 0, 1, 1))))
+		data := hb.Bytes()[:33]
+		binary.BigEndian.PutUint32(data[16:], 100000)
+		binary.BigEndian.PutUint32(data[20:], 100000)
+		binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+		_, err = media.MakeThumbnail(&gox.Image{Data: data}, 100, 100, nil)
+		require.True(t, errors.Is(err, media.ErrImageTooLarge))
+	})
+}
@@ -0,0 +1,31 @@
+package mobile
+
+import (
+	"github.com/gopub/gox"
+)
+
+// ErrorCatalog is loaded from JSON exported by gox.ErrorCatalog
+type ErrorCatalog struct {
+	c *gox.ErrorCatalog
+}
+
+func NewErrorCatalog(data []byte) (*ErrorCatalog, error) {
+	c, err := gox.ParseErrorCatalog(data)
+	if err != nil {
+		return nil, err
+	}
+	return &ErrorCatalog{c: c}, nil
+}
+
+// NewError creates error of code with message rendered in locale. args can be nil
+func (c *ErrorCatalog) NewError(code int, locale string, args *Map) *Error {
+	d, ok := c.c.GetByCode(code)
+	if !ok {
+		return NewError(code, "")
+	}
+	var m gox.M
+	if args != nil {
+		m = args.m
+	}
+	return NewError(code, d.Render(m, locale))
+}
@@ -0,0 +1,148 @@
+package preview
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gopub/gox"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	ErrNotHTML gox.ErrorString = "not html"
+
+	DefaultMaxBodySize int64 = 1 << 20
+	DefaultTimeout           = 10 * time.Second
+	DefaultUserAgent         = "Mozilla/5.0 (compatible; gox-preview/1.0)"
+)
+
+// Fetcher fetches web pages and builds previews
+type Fetcher struct {
+	client *http.Client
+
+	// MaxBodySize limits bytes read from html and oembed responses
+	MaxBodySize int64
+	// Timeout limits duration of each Fetch including oembed request
+	Timeout   time.Duration
+	UserAgent string
+	// OEmbed enables requesting discovered oembed endpoint if title or image is missing
+	OEmbed bool
+}
+
+// NewFetcher creates a fetcher. http.DefaultClient is used if client is nil
+func NewFetcher(client *http.Client) *Fetcher {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Fetcher{
+		client:      client,
+		MaxBodySize: DefaultMaxBodySize,
+		Timeout:     DefaultTimeout,
+		UserAgent:   DefaultUserAgent,
+		OEmbed:      true,
+	}
+}
+
+// Fetch fetches html document of link and builds web page
+func (f *Fetcher) Fetch(ctx context.Context, link string) (*gox.WebPage, error) {
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+	}
+
+	resp, err := f.get(ctx, link, "text/html,application/xhtml+xml")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	contentType := resp.Header.Get("Content-Type")
+	if mt, _, _ := mime.ParseMediaType(contentType); mt != "" && mt != "text/html" && mt != "application/xhtml+xml" {
+		return nil, fmt.Errorf("%w: %s", ErrNotHTML, mt)
+	}
+	body, err := charset.NewReader(f.limit(resp.Body), contentType)
+	if err != nil {
+		return nil, fmt.Errorf("detect charset: %w", err)
+	}
+	// use final url after redirects
+	m, err := ParseMetadata(body, resp.Request.URL.String())
+	if err != nil {
+		return nil, fmt.Errorf("parse: %w", err)
+	}
+	page := m.WebPage()
+	if f.OEmbed && m.OEmbedURL != "" && (page.Title == "" || page.Image == nil) {
+		// oembed is optional, ignore its error
+		if o, err := f.fetchOEmbed(ctx, m.OEmbedURL); err == nil {
+			o.fill(page)
+		}
+	}
+	return page, nil
+}
+
+func (f *Fetcher) get(ctx context.Context, link, accept string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, link, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request: %w", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", accept)
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, gox.NewError(resp.StatusCode, "fetch %s: %s", link, resp.Status)
+	}
+	return resp, nil
+}
+
+func (f *Fetcher) limit(r io.Reader) io.Reader {
+	if f.MaxBodySize <= 0 {
+		return r
+	}
+	return io.LimitReader(r, f.MaxBodySize)
+}
+
+type oEmbed struct {
+	Title           string `json:"title"`
+	ThumbnailURL    string `json:"thumbnail_url"`
+	ThumbnailWidth  int    `json:"thumbnail_width"`
+	ThumbnailHeight int    `json:"thumbnail_height"`
+}
+
+func (f *Fetcher) fetchOEmbed(ctx context.Context, link string) (*oEmbed, error) {
+	resp, err := f.get(ctx, link, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var o oEmbed
+	if err = json.NewDecoder(f.limit(resp.Body)).Decode(&o); err != nil {
+		return nil, fmt.Errorf("decode oembed: %w", err)
+	}
+	return &o, nil
+}
+
+func (o *oEmbed) fill(page *gox.WebPage) {
+	if page.Title == "" {
+		page.Title = strings.TrimSpace(o.Title)
+	}
+	if page.Image == nil && o.ThumbnailURL != "" {
+		page.Image = &gox.Image{
+			Link:   o.ThumbnailURL,
+			Width:  o.ThumbnailWidth,
+			Height: o.ThumbnailHeight,
+		}
+	}
+}
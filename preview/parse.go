@@ -0,0 +1,216 @@
+// Package preview extracts link previews from web pages
+package preview
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gopub/gox"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Metadata is preview related metadata extracted from html document
+type Metadata struct {
+	// OpenGraph contains og:* properties without prefix, e.g. title, image, image:width
+	OpenGraph map[string]string
+	// Twitter contains twitter:* properties without prefix, e.g. card, title, image
+	Twitter map[string]string
+	// OEmbedURL is discovered by <link rel="alternate" type="application/json+oembed">
+	OEmbedURL   string
+	Title       string
+	Description string
+	Canonical   string
+	// Icon is the first <link rel="icon">
+	Icon string
+
+	base *url.URL
+}
+
+// ParseMetadata parses html document read from r. Relative links are resolved against baseURL.
+func ParseMetadata(r io.Reader, baseURL string) (*Metadata, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	m := &Metadata{
+		OpenGraph: make(map[string]string),
+		Twitter:   make(map[string]string),
+		base:      base,
+	}
+
+	z := html.NewTokenizer(r)
+	inTitle := false
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return m, nil
+			}
+			return nil, z.Err()
+		case html.TextToken:
+			if inTitle && m.Title == "" {
+				m.Title = normalizeSpace(string(z.Text()))
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				// metadata lives in head
+				return m, nil
+			}
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := z.TagName()
+			a := atom.Lookup(name)
+			switch a {
+			case atom.Title:
+				inTitle = tt == html.StartTagToken
+			case atom.Body:
+				return m, nil
+			case atom.Meta, atom.Link:
+				if hasAttr {
+					m.handleTag(a, readAttrs(z))
+				}
+			}
+		}
+	}
+}
+
+func readAttrs(z *html.Tokenizer) map[string]string {
+	attrs := make(map[string]string)
+	for {
+		k, v, more := z.TagAttr()
+		attrs[strings.ToLower(string(k))] = string(v)
+		if !more {
+			return attrs
+		}
+	}
+}
+
+func (m *Metadata) handleTag(a atom.Atom, attrs map[string]string) {
+	if a == atom.Link {
+		rel := strings.Fields(strings.ToLower(attrs["rel"]))
+		href := strings.TrimSpace(attrs["href"])
+		if href == "" {
+			return
+		}
+		for _, r := range rel {
+			switch r {
+			case "canonical":
+				if m.Canonical == "" {
+					m.Canonical = m.resolve(href)
+				}
+			case "icon":
+				if m.Icon == "" {
+					m.Icon = m.resolve(href)
+				}
+			case "alternate":
+				if m.OEmbedURL == "" && strings.ToLower(attrs["type"]) == "application/json+oembed" {
+					m.OEmbedURL = m.resolve(href)
+				}
+			}
+		}
+		return
+	}
+
+	content := strings.TrimSpace(attrs["content"])
+	if content == "" {
+		return
+	}
+	// some sites use name instead of property for og tags
+	key := strings.ToLower(attrs["property"])
+	if key == "" {
+		key = strings.ToLower(attrs["name"])
+	}
+	switch {
+	case strings.HasPrefix(key, "og:"):
+		key = key[3:]
+		// og:image:url is alias of og:image
+		if key == "image:url" || key == "image:secure_url" {
+			key = "image"
+		}
+		if _, ok := m.OpenGraph[key]; !ok {
+			m.OpenGraph[key] = content
+		}
+	case strings.HasPrefix(key, "twitter:"):
+		key = key[8:]
+		if key == "image:src" {
+			key = "image"
+		}
+		if _, ok := m.Twitter[key]; !ok {
+			m.Twitter[key] = content
+		}
+	case key == "description":
+		if m.Description == "" {
+			m.Description = normalizeSpace(content)
+		}
+	}
+}
+
+func (m *Metadata) resolve(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return m.base.ResolveReference(u).String()
+}
+
+// WebPage builds web page preferring Open Graph, then Twitter Card, then standard html tags
+func (m *Metadata) WebPage() *gox.WebPage {
+	p := &gox.WebPage{
+		Title:   firstNonEmpty(m.OpenGraph["title"], m.Twitter["title"], m.Title),
+		Summary: firstNonEmpty(m.OpenGraph["description"], m.Twitter["description"], m.Description),
+		Link:    firstNonEmpty(m.resolveNonEmpty(m.OpenGraph["url"]), m.Canonical, m.base.String()),
+	}
+	if link := m.resolveNonEmpty(m.OpenGraph["image"]); link != "" {
+		p.Image = &gox.Image{
+			Link:   link,
+			Width:  atoi(m.OpenGraph["image:width"]),
+			Height: atoi(m.OpenGraph["image:height"]),
+			Format: strings.TrimPrefix(m.OpenGraph["image:type"], "image/"),
+		}
+	} else if link = m.resolveNonEmpty(m.Twitter["image"]); link != "" {
+		p.Image = &gox.Image{Link: link}
+	}
+	return p
+}
+
+func (m *Metadata) resolveNonEmpty(link string) string {
+	if link == "" {
+		return ""
+	}
+	return m.resolve(link)
+}
+
+// Parse parses html document read from r into web page
+func Parse(r io.Reader, baseURL string) (*gox.WebPage, error) {
+	m, err := ParseMetadata(r, baseURL)
+	if err != nil {
+		return nil, err
+	}
+	return m.WebPage(), nil
+}
+
+func firstNonEmpty(a ...string) string {
+	for _, s := range a {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+func atoi(s string) int {
+	i, _ := strconv.Atoi(s)
+	return i
+}
+
+func normalizeSpace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
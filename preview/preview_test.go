@@ -0,0 +1,143 @@
+package preview_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopub/gox"
+	"github.com/gopub/gox/preview"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("OpenGraph", func(t *testing.T) {
+		doc := `<html><head>
+<title>Fallback</title>
+<meta property="og:title" content="Hello &amp; World">
+<meta property="og:description" content="OG description">
+<meta property="og:image" content="/img/a.png">
+<meta property="og:image:width" content="1200">
+<meta property="og:image:height" content="630">
+<meta property="og:image:type" content="image/png">
+<meta name="twitter:title" content="Twitter title">
+</head><body></body></html>`
+		p, err := preview.Parse(strings.NewReader(doc), "https://example.com/posts/1")
+		require.NoError(t, err)
+		require.Equal(t, &gox.WebPage{
+			Title:   "Hello & World",
+			Summary: "OG description",
+			Link:    "https://example.com/posts/1",
+			Image: &gox.Image{
+				Link:   "https://example.com/img/a.png",
+				Width:  1200,
+				Height: 630,
+				Format: "png",
+			},
+		}, p)
+	})
+
+	t.Run("TwitterCard", func(t *testing.T) {
+		doc := `<head><meta name="twitter:card" content="summary">
+<meta name="twitter:title" content="Card title">
+<meta name="twitter:image" content="../b.jpg">
+<link rel="canonical" href="/canonical"></head>`
+		p, err := preview.Parse(strings.NewReader(doc), "https://example.com/a/b/c")
+		require.NoError(t, err)
+		require.Equal(t, "Card title", p.Title)
+		require.Equal(t, "https://example.com/a/b.jpg", p.Image.Link)
+		require.Equal(t, "https://example.com/canonical", p.Link)
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		doc := `<html><head><title>
+  Plain   page </title><meta name="description" content="A plain page"></head><body><p>text</p></body></html>`
+		p, err := preview.Parse(strings.NewReader(doc), "https://example.com")
+		require.NoError(t, err)
+		require.Equal(t, "Plain page", p.Title)
+		require.Equal(t, "A plain page", p.Summary)
+		require.Nil(t, p.Image)
+	})
+
+	t.Run("OEmbedDiscovery", func(t *testing.T) {
+		doc := `<head><link rel="alternate" type="application/json+oembed" href="/oembed?url=x"></head>`
+		m, err := preview.ParseMetadata(strings.NewReader(doc), "https://example.com/v")
+		require.NoError(t, err)
+		require.Equal(t, "https://example.com/oembed?url=x", m.OEmbedURL)
+	})
+}
+
+func TestFetcher(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<html><head><meta property="og:description" content="desc">
+<link rel="alternate" type="application/json+oembed" href="/oembed"></head></html>`)
+	})
+	mux.HandleFunc("/oembed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"title":"Video","thumbnail_url":"https://example.com/t.jpg","thumbnail_width":480,"thumbnail_height":360}`)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/page", http.StatusFound)
+	})
+	mux.HandleFunc("/json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{}`)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+	mux.HandleFunc("/large", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>`+strings.Repeat("a", 1000)+`</title></head></html>`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	f := preview.NewFetcher(server.Client())
+
+	t.Run("OEmbed", func(t *testing.T) {
+		p, err := f.Fetch(context.Background(), server.URL+"/redirect")
+		require.NoError(t, err)
+		require.Equal(t, "Video", p.Title)
+		require.Equal(t, "desc", p.Summary)
+		require.Equal(t, server.URL+"/page", p.Link)
+		require.Equal(t, &gox.Image{Link: "https://example.com/t.jpg", Width: 480, Height: 360}, p.Image)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, err := f.Fetch(context.Background(), server.URL+"/missing")
+		var e *gox.Error
+		require.True(t, errors.As(err, &e))
+		require.Equal(t, http.StatusNotFound, e.Code)
+	})
+
+	t.Run("NotHTML", func(t *testing.T) {
+		_, err := f.Fetch(context.Background(), server.URL+"/json")
+		require.True(t, errors.Is(err, preview.ErrNotHTML))
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		f := preview.NewFetcher(server.Client())
+		f.Timeout = 50 * time.Millisecond
+		_, err := f.Fetch(context.Background(), server.URL+"/slow")
+		require.Error(t, err)
+	})
+
+	t.Run("MaxBodySize", func(t *testing.T) {
+		f := preview.NewFetcher(server.Client())
+		f.MaxBodySize = 100
+		p, err := f.Fetch(context.Background(), server.URL+"/large")
+		require.NoError(t, err)
+		require.True(t, len(p.Title) < 100)
+	})
+}
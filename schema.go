@@ -0,0 +1,391 @@
+package gox
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Schema types
+const (
+	SchemaString  = "string"
+	SchemaInteger = "integer"
+	SchemaNumber  = "number"
+	SchemaBoolean = "boolean"
+	SchemaObject  = "object"
+	SchemaArray   = "array"
+	SchemaNull    = "null"
+)
+
+// Schema describes structure of M. It's a subset of JSON Schema draft-07
+type Schema struct {
+	Type        string             `json:"type,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	// AdditionalProperties is true if nil
+	AdditionalProperties *bool         `json:"additionalProperties,omitempty"`
+	Items                *Schema       `json:"items,omitempty"`
+	Enum                 []interface{} `json:"enum,omitempty"`
+	Minimum              *float64      `json:"minimum,omitempty"`
+	Maximum              *float64      `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64      `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64      `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int          `json:"minLength,omitempty"`
+	MaxLength            *int          `json:"maxLength,omitempty"`
+	MinItems             *int          `json:"minItems,omitempty"`
+	MaxItems             *int          `json:"maxItems,omitempty"`
+	UniqueItems          bool          `json:"uniqueItems,omitempty"`
+	Pattern              string        `json:"pattern,omitempty"`
+	// Format is name of format registered by RegisterSchemaFormat
+	Format string `json:"format,omitempty"`
+}
+
+// ParseSchema parses JSON schema and checks whether it's supported
+func ParseSchema(data []byte) (*Schema, error) {
+	var s *Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("unmarshal: %w", err)
+	}
+	if s == nil {
+		return nil, fmt.Errorf("null schema")
+	}
+	if err := s.Check(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Check checks types, patterns and formats of s and its sub schemas
+func (s *Schema) Check() error {
+	return s.check("")
+}
+
+func (s *Schema) check(path string) error {
+	switch s.Type {
+	case "", SchemaString, SchemaInteger, SchemaNumber, SchemaBoolean, SchemaObject, SchemaArray, SchemaNull:
+	default:
+		return fmt.Errorf("%s: unsupported type %s", schemaPathName(path), s.Type)
+	}
+	if s.Pattern != "" {
+		if _, err := compileSchemaPattern(s.Pattern); err != nil {
+			return fmt.Errorf("%s: %w", schemaPathName(path), err)
+		}
+	}
+	if s.Format != "" && getSchemaFormat(s.Format) == nil {
+		return fmt.Errorf("%s: unknown format %s", schemaPathName(path), s.Format)
+	}
+	for name, p := range s.Properties {
+		if p == nil {
+			return fmt.Errorf("%s: null schema", schemaPathName(joinPath(path, name)))
+		}
+		if err := p.check(joinPath(path, name)); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.check(path + "[]")
+	}
+	return nil
+}
+
+// FieldViolation describes an invalid field
+type FieldViolation struct {
+	// Field is path of field, e.g. user.addresses[0].city
+	Field       string `json:"field"`
+	Description string `json:"description"`
+}
+
+// ValidationError contains all violations found by validation
+type ValidationError struct {
+	Violations []*FieldViolation `json:"violations"`
+}
+
+func (e *ValidationError) Error() string {
+	a := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		a[i] = v.Field + ": " + v.Description
+	}
+	return strings.Join(a, "; ")
+}
+
+// Add adds a violation
+func (e *ValidationError) Add(field, format string, args ...interface{}) {
+	e.Violations = append(e.Violations, &FieldViolation{
+		Field:       field,
+		Description: fmt.Sprintf(format, args...),
+	})
+}
+
+// BadRequest converts e to bad request error carrying e as detail
+func (e *ValidationError) BadRequest() *Error {
+	return BadRequest("%s", e.Error()).WithDetails(e)
+}
+
+// Validate validates m and returns *ValidationError containing all violations
+func (s *Schema) Validate(m M) error {
+	e := &ValidationError{}
+	s.validate(e, "", map[string]interface{}(m))
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (s *Schema) validate(e *ValidationError, path string, v interface{}) {
+	field := schemaPathName(path)
+	if s.Type != "" && !isSchemaType(s.Type, v) {
+		e.Add(field, "must be %s", s.Type)
+		return
+	}
+	if len(s.Enum) > 0 {
+		found := false
+		for _, ev := range s.Enum {
+			if jsonEqual(ev, v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			e.Add(field, "must be one of %s", enumString(s.Enum))
+			return
+		}
+	}
+
+	switch tv := v.(type) {
+	case string:
+		s.validateString(e, field, tv)
+		return
+	case bool, nil:
+		return
+	}
+
+	if f, ok := schemaNumber(v); ok {
+		s.validateNumber(e, field, f)
+		return
+	}
+
+	if mv, ok := toStringMap(v); ok {
+		s.validateObject(e, path, mv)
+		return
+	}
+
+	if a, ok := toSlice(v); ok {
+		s.validateArray(e, path, a)
+	}
+}
+
+func (s *Schema) validateString(e *ValidationError, field, v string) {
+	n := utf8.RuneCountInString(v)
+	if s.MinLength != nil && n < *s.MinLength {
+		e.Add(field, "length must be at least %d", *s.MinLength)
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		e.Add(field, "length must be at most %d", *s.MaxLength)
+	}
+	if s.Pattern != "" {
+		re, err := compileSchemaPattern(s.Pattern)
+		if err != nil || !re.MatchString(v) {
+			e.Add(field, "must match pattern %s", s.Pattern)
+		}
+	}
+	if s.Format != "" {
+		f := getSchemaFormat(s.Format)
+		if f == nil || !f(v) {
+			e.Add(field, "must be valid %s", s.Format)
+		}
+	}
+}
+
+func (s *Schema) validateNumber(e *ValidationError, field string, v float64) {
+	if s.Minimum != nil && v < *s.Minimum {
+		e.Add(field, "must be >= %v", *s.Minimum)
+	}
+	if s.Maximum != nil && v > *s.Maximum {
+		e.Add(field, "must be <= %v", *s.Maximum)
+	}
+	if s.ExclusiveMinimum != nil && v <= *s.ExclusiveMinimum {
+		e.Add(field, "must be > %v", *s.ExclusiveMinimum)
+	}
+	if s.ExclusiveMaximum != nil && v >= *s.ExclusiveMaximum {
+		e.Add(field, "must be < %v", *s.ExclusiveMaximum)
+	}
+}
+
+func (s *Schema) validateObject(e *ValidationError, path string, m map[string]interface{}) {
+	for _, name := range s.Required {
+		if v, ok := m[name]; !ok || v == nil {
+			e.Add(schemaPathName(joinPath(path, name)), "is required")
+		}
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p, ok := s.Properties[k]
+		if !ok {
+			if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+				e.Add(schemaPathName(joinPath(path, k)), "is not allowed")
+			}
+			continue
+		}
+		if m[k] == nil && p.Type != SchemaNull {
+			// null is treated as absence which is checked by required
+			continue
+		}
+		p.validate(e, joinPath(path, k), m[k])
+	}
+}
+
+func (s *Schema) validateArray(e *ValidationError, path string, a []interface{}) {
+	field := schemaPathName(path)
+	if s.MinItems != nil && len(a) < *s.MinItems {
+		e.Add(field, "must contain at least %d items", *s.MinItems)
+	}
+	if s.MaxItems != nil && len(a) > *s.MaxItems {
+		e.Add(field, "must contain at most %d items", *s.MaxItems)
+	}
+	if s.UniqueItems {
+		for i := 1; i < len(a); i++ {
+			if indexOfElement(a[:i], a[i], "") >= 0 {
+				e.Add(field, "must contain unique items")
+				break
+			}
+		}
+	}
+	if s.Items != nil {
+		for i, v := range a {
+			s.Items.validate(e, fmt.Sprintf("%s[%d]", path, i), v)
+		}
+	}
+}
+
+func isSchemaType(typ string, v interface{}) bool {
+	switch typ {
+	case SchemaString:
+		_, ok := v.(string)
+		return ok
+	case SchemaBoolean:
+		_, ok := v.(bool)
+		return ok
+	case SchemaNull:
+		return v == nil
+	case SchemaNumber:
+		_, ok := schemaNumber(v)
+		return ok
+	case SchemaInteger:
+		f, ok := schemaNumber(v)
+		return ok && f == math.Trunc(f)
+	case SchemaObject:
+		_, ok := toStringMap(v)
+		return ok
+	case SchemaArray:
+		_, ok := toSlice(v)
+		return ok
+	default:
+		return false
+	}
+}
+
+func schemaNumber(v interface{}) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	default:
+		return 0, false
+	}
+}
+
+func enumString(a []interface{}) string {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Sprint(a)
+	}
+	return string(data)
+}
+
+func joinPath(path, key string) string {
+	if strings.ContainsAny(key, ".[]") {
+		return path + `["` + key + `"]`
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func schemaPathName(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return path
+}
+
+var schemaPatterns sync.Map
+
+func compileSchemaPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := schemaPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("compile pattern: %w", err)
+	}
+	schemaPatterns.Store(pattern, re)
+	return re, nil
+}
+
+var (
+	schemaFormatsMu sync.RWMutex
+	schemaFormats   = map[string]func(s string) bool{
+		"email":     IsEmail,
+		"phone":     IsPhoneNumber,
+		"link":      IsLink,
+		"uri":       IsLink,
+		"name":      IsName,
+		"birthdate": IsBirthDate,
+		"date": func(s string) bool {
+			_, err := time.Parse("2006-01-02", s)
+			return err == nil
+		},
+		"date-time": func(s string) bool {
+			_, err := time.Parse(time.RFC3339, s)
+			return err == nil
+		},
+	}
+)
+
+// RegisterSchemaFormat registers string format which can be used by Schema.Format
+func RegisterSchemaFormat(name string, isValid func(s string) bool) {
+	if isValid == nil {
+		panic("isValid is nil")
+	}
+	schemaFormatsMu.Lock()
+	schemaFormats[name] = isValid
+	schemaFormatsMu.Unlock()
+}
+
+func getSchemaFormat(name string) func(s string) bool {
+	schemaFormatsMu.RLock()
+	defer schemaFormatsMu.RUnlock()
+	return schemaFormats[name]
+}
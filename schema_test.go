@@ -0,0 +1,112 @@
+package gox_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gopub/gox"
+	"github.com/stretchr/testify/require"
+)
+
+const userSchema = `{
+	"type": "object",
+	"required": ["name", "email", "addresses"],
+	"additionalProperties": false,
+	"properties": {
+		"name": {"type": "string", "minLength": 2, "maxLength": 20, "pattern": "^[A-Z]"},
+		"email": {"type": "string", "format": "email"},
+		"phone": {"type": "string", "format": "phone"},
+		"birthday": {"type": "string", "format": "birthdate"},
+		"homepage": {"type": "string", "format": "link"},
+		"age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 150},
+		"gender": {"type": "string", "enum": ["male", "female"]},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 3, "uniqueItems": true},
+		"addresses": {
+			"type": "array",
+			"minItems": 1,
+			"items": {
+				"type": "object",
+				"required": ["city"],
+				"properties": {
+					"city": {"type": "string"},
+					"zip": {"type": "string", "pattern": "^[0-9]{6}$"}
+				}
+			}
+		}
+	}
+}`
+
+func TestSchema_Validate(t *testing.T) {
+	s, err := gox.ParseSchema([]byte(userSchema))
+	require.NoError(t, err)
+
+	t.Run("Valid", func(t *testing.T) {
+		m := parseM(t, `{
+			"name": "Tom",
+			"email": "tom@example.com",
+			"phone": "+8613800000000",
+			"birthday": "1990-01-02",
+			"homepage": "https://example.com",
+			"age": 20,
+			"gender": "male",
+			"tags": ["a", "b"],
+			"addresses": [{"city": "Beijing", "zip": "100000"}]
+		}`)
+		require.NoError(t, s.Validate(m))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		m := parseM(t, `{
+			"name": "tom",
+			"email": "tom",
+			"age": 20.5,
+			"gender": "x",
+			"tags": ["a", "a"],
+			"addresses": [{"zip": "1"}, "Beijing"],
+			"extra": 1
+		}`)
+		err := s.Validate(m)
+		var ve *gox.ValidationError
+		require.True(t, errors.As(err, &ve))
+		fields := map[string]string{}
+		for _, v := range ve.Violations {
+			fields[v.Field] = v.Description
+		}
+		require.Equal(t, map[string]string{
+			"name":              "must match pattern ^[A-Z]",
+			"email":             "must be valid email",
+			"age":               "must be integer",
+			"gender":            `must be one of ["male","female"]`,
+			"tags":              "must contain unique items",
+			"addresses[0].city": "is required",
+			"addresses[0].zip":  "must match pattern ^[0-9]{6}$",
+			"addresses[1]":      "must be object",
+			"extra":             "is not allowed",
+		}, fields)
+
+		be := ve.BadRequest()
+		require.Equal(t, http.StatusBadRequest, be.Code)
+		require.Contains(t, be.Message, "email: must be valid email")
+	})
+
+	t.Run("Required", func(t *testing.T) {
+		err := s.Validate(gox.M{"name": "Tom", "email": nil})
+		require.EqualError(t, err, "email: is required; addresses: is required")
+	})
+}
+
+func TestParseSchema(t *testing.T) {
+	_, err := gox.ParseSchema([]byte(`{"type":"object","properties":{"a":{"type":"str"}}}`))
+	require.Error(t, err)
+	_, err = gox.ParseSchema([]byte(`{"type":"string","pattern":"["}`))
+	require.Error(t, err)
+	_, err = gox.ParseSchema([]byte(`{"type":"string","format":"unknown"}`))
+	require.Error(t, err)
+
+	gox.RegisterSchemaFormat("upper", func(s string) bool { return s != "" && s[0] >= 'A' && s[0] <= 'Z' })
+	s, err := gox.ParseSchema([]byte(`{"type":"object","properties":{"a":{"type":"string","format":"upper"}}}`))
+	require.NoError(t, err)
+	require.NoError(t, s.Validate(gox.M{"a": "A"}))
+	require.Error(t, s.Validate(gox.M{"a": "a"}))
+}
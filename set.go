@@ -1,34 +1,88 @@
 package gox
 
-import "encoding/json"
+import (
+	"cmp"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
 
 type Void struct{}
 
-type Set struct {
-	items map[interface{}]Void
+// TypedSet is a collection of unique items of type T. Zero value is an empty set
+type TypedSet[T comparable] struct {
+	items map[T]Void
 }
 
+// Set is a set of items of any comparable types, it's kept for compatibility
+type Set = TypedSet[interface{}]
+
+// Int64Set is kept for compatibility
+type Int64Set = TypedSet[int64]
+
+// StringSet is kept for compatibility
+type StringSet = TypedSet[string]
+
+var (
+	_ json.Unmarshaler = (*TypedSet[int64])(nil)
+	_ json.Marshaler   = (*TypedSet[int64])(nil)
+	_ sql.Scanner      = (*TypedSet[int64])(nil)
+	_ driver.Valuer    = (*TypedSet[int64])(nil)
+)
+
 func NewSet(capacity int) *Set {
-	s := &Set{}
-	s.items = make(map[interface{}]Void, capacity)
+	return NewTypedSet[interface{}](capacity)
+}
+
+func NewTypedSet[T comparable](capacity int) *TypedSet[T] {
+	s := &TypedSet[T]{}
+	s.items = make(map[T]Void, capacity)
+	return s
+}
+
+// NewSetOf creates a set containing items
+func NewSetOf[T comparable](items ...T) *TypedSet[T] {
+	s := NewTypedSet[T](len(items))
+	s.Add(items...)
 	return s
 }
 
-func (s *Set) Add(item interface{}) {
-	s.items[item] = Void{}
+func NewInt64Set(capacity int) *Int64Set {
+	return NewTypedSet[int64](capacity)
 }
 
-func (s *Set) Contains(item interface{}) bool {
+func NewStringSet(capacity int) *StringSet {
+	return NewTypedSet[string](capacity)
+}
+
+func (s *TypedSet[T]) Add(items ...T) {
+	if s.items == nil {
+		s.items = make(map[T]Void, len(items))
+	}
+	for _, item := range items {
+		s.items[item] = Void{}
+	}
+}
+
+func (s *TypedSet[T]) Contains(item T) bool {
 	_, found := s.items[item]
 	return found
 }
 
-func (s *Set) Remove(item interface{}) {
-	delete(s.items, item)
+func (s *TypedSet[T]) Remove(items ...T) {
+	for _, item := range items {
+		delete(s.items, item)
+	}
 }
 
-func (s *Set) Slice() []interface{} {
-	l := make([]interface{}, 0, len(s.items))
+func (s *TypedSet[T]) Slice() []T {
+	l := make([]T, 0, len(s.items))
 	for k := range s.items {
 		l = append(l, k)
 	}
@@ -36,118 +90,311 @@ func (s *Set) Slice() []interface{} {
 	return l
 }
 
-type Int64Set struct {
-	items map[int64]Void
-}
-
-var _ json.Unmarshaler = (*Int64Set)(nil)
-var _ json.Marshaler = (*Int64Set)(nil)
-
-func NewInt64Set(capacity int) *Int64Set {
-	s := &Int64Set{}
-	s.items = make(map[int64]Void, capacity)
-	return s
+// SortedFunc returns items sorted by cmp
+func (s *TypedSet[T]) SortedFunc(cmp func(a, b T) int) []T {
+	l := s.Slice()
+	slices.SortFunc(l, cmp)
+	return l
 }
 
-func (s *Int64Set) Add(item int64) {
-	s.items[item] = Void{}
+// Sorted returns items of s in ascending order
+func Sorted[T cmp.Ordered](s *TypedSet[T]) []T {
+	l := s.Slice()
+	slices.Sort(l)
+	return l
 }
 
-func (s *Int64Set) Contains(item int64) bool {
-	_, found := s.items[item]
-	return found
+func (s *TypedSet[T]) Map() map[T]Void {
+	return s.items
 }
 
-func (s *Int64Set) Remove(item int64) {
-	delete(s.items, item)
+func (s *TypedSet[T]) Size() int {
+	return len(s.items)
 }
 
-func (s *Int64Set) Slice() []int64 {
-	l := make([]int64, 0, len(s.items))
+func (s *TypedSet[T]) Clone() *TypedSet[T] {
+	c := NewTypedSet[T](len(s.items))
 	for k := range s.items {
-		l = append(l, k)
+		c.items[k] = Void{}
 	}
+	return c
+}
 
-	return l
+// Union returns a new set containing items in s or o
+func (s *TypedSet[T]) Union(o *TypedSet[T]) *TypedSet[T] {
+	u := s.Clone()
+	for k := range o.items {
+		u.items[k] = Void{}
+	}
+	return u
 }
 
-func (s *Int64Set) Map() map[int64]Void {
-	return s.items
+// Intersection returns a new set containing items in both s and o
+func (s *TypedSet[T]) Intersection(o *TypedSet[T]) *TypedSet[T] {
+	small, large := s, o
+	if small.Size() > large.Size() {
+		small, large = large, small
+	}
+	res := NewTypedSet[T](small.Size())
+	for k := range small.items {
+		if large.Contains(k) {
+			res.items[k] = Void{}
+		}
+	}
+	return res
 }
 
-func (s *Int64Set) Size() int {
-	return len(s.items)
+// Difference returns a new set containing items in s but not in o
+func (s *TypedSet[T]) Difference(o *TypedSet[T]) *TypedSet[T] {
+	res := NewTypedSet[T](s.Size())
+	for k := range s.items {
+		if !o.Contains(k) {
+			res.items[k] = Void{}
+		}
+	}
+	return res
 }
 
-func (s *Int64Set) UnmarshalJSON(data []byte) error {
-	var ids []int64
-	if err := json.Unmarshal(data, &ids); err != nil {
-		return err
+// IsSubsetOf reports whether all items of s are in o
+func (s *TypedSet[T]) IsSubsetOf(o *TypedSet[T]) bool {
+	if s.Size() > o.Size() {
+		return false
 	}
-	for _, id := range ids {
-		s.Add(id)
+	for k := range s.items {
+		if !o.Contains(k) {
+			return false
+		}
 	}
-	return nil
+	return true
 }
 
-func (s *Int64Set) MarshalJSON() ([]byte, error) {
-	return json.Marshal(s.Slice())
+// IsSupersetOf reports whether all items of o are in s
+func (s *TypedSet[T]) IsSupersetOf(o *TypedSet[T]) bool {
+	return o.IsSubsetOf(s)
 }
 
-type StringSet struct {
-	items map[string]Void
+// Equal reports whether s and o contain the same items
+func (s *TypedSet[T]) Equal(o *TypedSet[T]) bool {
+	return s.Size() == o.Size() && s.IsSubsetOf(o)
 }
 
-var _ json.Unmarshaler = (*StringSet)(nil)
-var _ json.Marshaler = (*StringSet)(nil)
-
-func NewStringSet(capacity int) *StringSet {
-	s := &StringSet{}
-	s.items = make(map[string]Void, capacity)
-	return s
+// sortedSlice returns items in a deterministic order. Items of ordered kinds, e.g. strings and numbers,
+// are sorted by value, others are sorted by their formatted text
+func (s *TypedSet[T]) sortedSlice() []T {
+	l := s.Slice()
+	slices.SortFunc(l, func(a, b T) int {
+		return compareSetItems(a, b)
+	})
+	return l
 }
 
-func (s *StringSet) Add(item string) {
-	s.items[item] = Void{}
+func compareSetItems(a, b interface{}) int {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Kind() != vb.Kind() {
+		return cmp.Compare(va.Kind(), vb.Kind())
+	}
+	switch va.Kind() {
+	case reflect.String:
+		return cmp.Compare(va.String(), vb.String())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return cmp.Compare(va.Int(), vb.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return cmp.Compare(va.Uint(), vb.Uint())
+	case reflect.Float32, reflect.Float64:
+		return cmp.Compare(va.Float(), vb.Float())
+	case reflect.Bool:
+		if va.Bool() == vb.Bool() {
+			return 0
+		}
+		if vb.Bool() {
+			return -1
+		}
+		return 1
+	default:
+		return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+	}
 }
 
-func (s *StringSet) Contains(item string) bool {
-	_, found := s.items[item]
-	return found
+// UnmarshalJSON replaces items of s with items in JSON array. null results in an empty set
+func (s *TypedSet[T]) UnmarshalJSON(data []byte) error {
+	var l []T
+	if err := json.Unmarshal(data, &l); err != nil {
+		return err
+	}
+	s.items = make(map[T]Void, len(l))
+	s.Add(l...)
+	return nil
 }
 
-func (s *StringSet) Remove(item string) {
-	delete(s.items, item)
+// MarshalJSON returns JSON array of items in the order of sortedSlice
+func (s *TypedSet[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.sortedSlice())
 }
 
-func (s *StringSet) Slice() []string {
-	sl := make([]string, 0, len(s.items))
-	for k := range s.items {
-		sl = append(sl, k)
+// Scan replaces items of s with PostgreSQL array, e.g. {1,2,3}, or JSON array. NULL results in an empty set
+func (s *TypedSet[T]) Scan(src interface{}) error {
+	var str string
+	switch v := src.(type) {
+	case nil:
+		s.items = make(map[T]Void)
+		return nil
+	case string:
+		str = v
+	case []byte:
+		str = string(v)
+	default:
+		return fmt.Errorf("cannot scan %T into set", src)
 	}
-
-	return sl
+	str = strings.TrimSpace(str)
+	if strings.HasPrefix(str, "[") {
+		return s.UnmarshalJSON([]byte(str))
+	}
+	elems, err := parsePostgresArray(str)
+	if err != nil {
+		return err
+	}
+	items := make(map[T]Void, len(elems))
+	for _, e := range elems {
+		if e == nil {
+			continue
+		}
+		var v T
+		if err = parseArrayElem(*e, &v); err != nil {
+			return fmt.Errorf("parse %s: %w", *e, err)
+		}
+		items[v] = Void{}
+	}
+	s.items = items
+	return nil
 }
 
-func (s *StringSet) Map() map[string]Void {
-	return s.items
+// Value returns PostgreSQL array of items in the order of sortedSlice
+func (s *TypedSet[T]) Value() (driver.Value, error) {
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range s.sortedSlice() {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		if err := formatArrayElem(&b, k); err != nil {
+			return nil, err
+		}
+	}
+	b.WriteByte('}')
+	return b.String(), nil
 }
 
-func (s *StringSet) Size() int {
-	return len(s.items)
+// parsePostgresArray parses one dimensional array. nil element means NULL
+func parsePostgresArray(s string) ([]*string, error) {
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, errors.New("invalid array")
+	}
+	s = s[1 : len(s)-1]
+	var elems []*string
+	for i := 0; i < len(s); {
+		var e strings.Builder
+		quoted := s[i] == '"'
+		if quoted {
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				e.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, errors.New("unterminated quoted element")
+			}
+			i++
+		} else {
+			for ; i < len(s) && s[i] != ','; i++ {
+				e.WriteByte(s[i])
+			}
+		}
+		str := e.String()
+		if !quoted {
+			str = strings.TrimSpace(str)
+		}
+		if !quoted && strings.EqualFold(str, "NULL") {
+			elems = append(elems, nil)
+		} else {
+			elems = append(elems, &str)
+		}
+		if i < len(s) {
+			if s[i] != ',' {
+				return nil, errors.New("invalid array")
+			}
+			i++
+		}
+	}
+	return elems, nil
 }
 
-func (s *StringSet) UnmarshalJSON(data []byte) error {
-	var l []string
-	if err := json.Unmarshal(data, &l); err != nil {
-		return err
+func parseArrayElem(s string, dst interface{}) error {
+	v := reflect.ValueOf(dst).Elem()
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		v.SetBool(s == "t" || s == "true")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		i, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(i)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	default:
+		return json.Unmarshal([]byte(s), dst)
 	}
-	for _, v := range l {
-		s.Add(v)
+	return nil
+}
+
+func formatArrayElem(b *strings.Builder, e interface{}) error {
+	v := reflect.ValueOf(e)
+	switch v.Kind() {
+	case reflect.String:
+		quoteArrayElem(b, v.String())
+	case reflect.Bool:
+		if v.Bool() {
+			b.WriteByte('t')
+		} else {
+			b.WriteByte('f')
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		b.WriteString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		b.WriteString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		b.WriteString(strconv.FormatFloat(v.Float(), 'g', -1, v.Type().Bits()))
+	default:
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		quoteArrayElem(b, string(data))
 	}
 	return nil
 }
 
-func (s *StringSet) MarshalJSON() ([]byte, error) {
-	return json.Marshal(s.Slice())
+func quoteArrayElem(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if s[i] == '"' || s[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
 }
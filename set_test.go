@@ -0,0 +1,123 @@
+package gox_test
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gopub/gox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSet(t *testing.T) {
+	a := gox.NewSetOf(1, 2, 3)
+	b := gox.NewSetOf(3, 4)
+	require.Equal(t, []int{1, 2, 3, 4}, gox.Sorted(a.Union(b)))
+	require.Equal(t, []int{3}, gox.Sorted(a.Intersection(b)))
+	require.Equal(t, []int{1, 2}, gox.Sorted(a.Difference(b)))
+	require.True(t, gox.NewSetOf(1, 3).IsSubsetOf(a))
+	require.False(t, b.IsSubsetOf(a))
+	require.True(t, a.IsSupersetOf(gox.NewSetOf(2)))
+	require.True(t, a.Equal(gox.NewSetOf(3, 2, 1)))
+	require.Equal(t, []int{3, 2, 1}, a.SortedFunc(func(x, y int) int { return y - x }))
+
+	var zero gox.TypedSet[string]
+	require.False(t, zero.Contains("a"))
+	zero.Add("a")
+	require.True(t, zero.Contains("a"))
+}
+
+func TestSet_Untyped(t *testing.T) {
+	var s *gox.Set = gox.NewSet(2)
+	s.Add("a")
+	s.Add(1)
+	require.True(t, s.Contains(1))
+	require.True(t, s.Contains("a"))
+	require.False(t, s.Contains(int64(1)))
+	s.Remove("a")
+	require.Equal(t, []interface{}{1}, s.Slice())
+
+	data, err := json.Marshal(gox.NewSetOf[interface{}]("b", 2, "a", 1))
+	require.NoError(t, err)
+	require.Equal(t, `[1,2,"a","b"]`, string(data))
+
+	var ids *gox.Int64Set = gox.NewInt64Set(1)
+	ids.Add(1)
+	require.Equal(t, []int64{1}, ids.Slice())
+}
+
+func TestSet_JSON(t *testing.T) {
+	var s gox.Int64Set
+	require.NoError(t, json.Unmarshal([]byte(`[3,1,2,1]`), &s))
+	require.Equal(t, 3, s.Size())
+	require.Equal(t, []int64{1, 2, 3}, gox.Sorted(&s))
+
+	data, err := json.Marshal(gox.NewSetOf("a"))
+	require.NoError(t, err)
+	require.Equal(t, `["a"]`, string(data))
+
+	data, err = json.Marshal(gox.NewSetOf(10, 9, 1, 100))
+	require.NoError(t, err)
+	require.Equal(t, `[1,9,10,100]`, string(data))
+
+	require.NoError(t, json.Unmarshal([]byte(`[5]`), &s))
+	require.Equal(t, []int64{5}, gox.Sorted(&s))
+	require.NoError(t, json.Unmarshal([]byte(`null`), &s))
+	require.Zero(t, s.Size())
+}
+
+func TestSet_SQL(t *testing.T) {
+	s := gox.NewSetOf(`a"b`, `c\d`, "e,f")
+	v, err := s.Value()
+	require.NoError(t, err)
+	var scanned gox.StringSet
+	require.NoError(t, scanned.Scan(v))
+	require.True(t, s.Equal(&scanned))
+
+	var ids gox.TypedSet[gox.ID]
+	require.NoError(t, ids.Scan([]byte("{1,2,NULL}")))
+	require.Equal(t, []gox.ID{1, 2}, gox.Sorted(&ids))
+	v, err = ids.Value()
+	require.NoError(t, err)
+	elems := strings.Split(strings.Trim(v.(string), "{}"), ",")
+	require.Len(t, elems, 2)
+	for _, e := range elems {
+		_, err = strconv.Atoi(e)
+		require.NoError(t, err)
+	}
+
+	var fromJSON gox.TypedSet[int]
+	require.NoError(t, fromJSON.Scan(`[1,2]`))
+	require.Equal(t, 2, fromJSON.Size())
+	require.Error(t, fromJSON.Scan("{1,x}"))
+	require.Equal(t, 2, fromJSON.Size())
+
+	// Scan replaces previous items
+	require.NoError(t, fromJSON.Scan("{3}"))
+	require.Equal(t, []int{3}, gox.Sorted(&fromJSON))
+	require.NoError(t, fromJSON.Scan(nil))
+	require.Zero(t, fromJSON.Size())
+
+	v, err = gox.NewSetOf("b", "c", "a").Value()
+	require.NoError(t, err)
+	require.Equal(t, `{"a","b","c"}`, v)
+	v, err = gox.NewSetOf[int64](10, 2, -1).Value()
+	require.NoError(t, err)
+	require.Equal(t, "{-1,2,10}", v)
+}
+
+func TestSliceHelpers(t *testing.T) {
+	s := []int{1, 2, 3, 4, 5}
+	require.Equal(t, []string{"1", "2", "3", "4", "5"}, gox.Map(s, strconv.Itoa))
+	require.Equal(t, []int{2, 4}, gox.Filter(s, func(v int) bool { return v%2 == 0 }))
+	require.Equal(t, 15, gox.Reduce(s, 0, func(sum, v int) int { return sum + v }))
+	require.Equal(t, [][]int{{1, 2}, {3, 4}, {5}}, gox.Chunk(s, 2))
+	require.Equal(t, []int{3, 1, 2}, gox.Uniq([]int{3, 1, 3, 2, 1}))
+	require.Equal(t, map[bool][]int{true: {2, 4}, false: {1, 3, 5}}, gox.GroupBy(s, func(v int) bool { return v%2 == 0 }))
+	odd, even := gox.Partition(s, func(v int) bool { return v%2 == 1 })
+	require.Equal(t, []int{1, 3, 5}, odd)
+	require.Equal(t, []int{2, 4}, even)
+	require.Equal(t, 2, gox.IndexOf([]string{"a", "b", "c"}, "c"))
+	require.Equal(t, []string{"a", "c"}, gox.RemoveString([]string{"a", "b", "c", "b"}, "b"))
+}
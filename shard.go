@@ -0,0 +1,319 @@
+package gox
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopub/log"
+)
+
+// ShardIDResolver resolves the shard id of current process
+type ShardIDResolver interface {
+	ResolveShardID() (int64, error)
+}
+
+type ShardIDResolverFunc func() (int64, error)
+
+func (f ShardIDResolverFunc) ResolveShardID() (int64, error) {
+	return f()
+}
+
+// DefaultShardIDEnv is the env variable checked by DefaultShardIDResolver
+const DefaultShardIDEnv = "GOX_SHARD_ID"
+
+// DefaultShardIDResolver resolves shard id for NextID, NextSlowID and NextFastID. It's called for every id,
+// the default one resolves lazily and caches the result. It can be replaced during initialization, e.g. with a ShardLease.
+var DefaultShardIDResolver ShardIDResolver = NewLazyShardIDGetter(ChainShardIDResolvers(
+	ShardIDByEnv(DefaultShardIDEnv),
+	ShardIDByIP,
+	ShardIDByMacAddr,
+	ShardIDByHostname,
+))
+
+// ShardIDByEnv returns a resolver which parses shard id from env variable key
+func ShardIDByEnv(key string) ShardIDResolver {
+	return ShardIDResolverFunc(func() (int64, error) {
+		s, ok := os.LookupEnv(key)
+		if !ok {
+			return 0, fmt.Errorf("env %s: %w", key, ErrNotExist)
+		}
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("parse env %s: %w", key, err)
+		}
+		if id < 0 {
+			return 0, fmt.Errorf("env %s: negative shard id %d", key, id)
+		}
+		return id, nil
+	})
+}
+
+// ShardIDByIP resolves shard id from the outbound ip
+var ShardIDByIP ShardIDResolverFunc = func() (int64, error) {
+	ip, err := GetOutboundIP()
+	if err != nil {
+		return 0, fmt.Errorf("get outbound ip: %w", err)
+	}
+
+	ipBytes := []byte(ip)
+	if ip4 := ip.To4(); ip4 != nil {
+		ipBytes = ip4
+	} else if len(ipBytes) > 8 {
+		ipBytes = ipBytes[len(ipBytes)-8:]
+	}
+	var num int64 = 0
+	for _, b := range ipBytes {
+		num <<= 8
+		num |= int64(b)
+	}
+	return num & (1<<63 - 1), nil
+}
+
+// ShardIDByHostname resolves shard id from the hash of hostname
+var ShardIDByHostname ShardIDResolverFunc = func() (int64, error) {
+	name, err := os.Hostname()
+	if err != nil {
+		return 0, fmt.Errorf("get hostname: %w", err)
+	}
+	if len(name) == 0 {
+		return 0, errors.New("empty hostname")
+	}
+	return hashShardID(name), nil
+}
+
+// ShardIDByMacAddr resolves shard id from the hash of mac addresses
+var ShardIDByMacAddr ShardIDResolverFunc = func() (int64, error) {
+	addrs, err := GetMacAddrs()
+	if err != nil {
+		return 0, fmt.Errorf("get mac addrs: %w", err)
+	}
+	b := &strings.Builder{}
+	for _, a := range addrs {
+		b.WriteString(a)
+	}
+	if b.Len() == 0 {
+		return 0, errors.New("no mac address")
+	}
+	return hashShardID(b.String()), nil
+}
+
+func hashShardID(s string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return int64(h.Sum64() & (1<<63 - 1))
+}
+
+// ChainShardIDResolvers returns a resolver which returns the first shard id resolved successfully by resolvers
+func ChainShardIDResolvers(resolvers ...ShardIDResolver) ShardIDResolver {
+	return ShardIDResolverFunc(func() (int64, error) {
+		var errs []string
+		for _, r := range resolvers {
+			id, err := r.ResolveShardID()
+			if err == nil {
+				return id, nil
+			}
+			errs = append(errs, err.Error())
+		}
+		return 0, fmt.Errorf("resolve shard id: %s", strings.Join(errs, "; "))
+	})
+}
+
+// LazyShardIDGetter resolves shard id on first use and caches the result. Failed resolution is retried on next use
+type LazyShardIDGetter struct {
+	resolver ShardIDResolver
+	mu       sync.Mutex
+	resolved bool
+	id       int64
+}
+
+var _ NumberGetter = (*LazyShardIDGetter)(nil)
+var _ ShardIDResolver = (*LazyShardIDGetter)(nil)
+
+func NewLazyShardIDGetter(resolver ShardIDResolver) *LazyShardIDGetter {
+	if resolver == nil {
+		panic("resolver is nil")
+	}
+	return &LazyShardIDGetter{resolver: resolver}
+}
+
+func (g *LazyShardIDGetter) ResolveShardID() (int64, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resolved {
+		return g.id, nil
+	}
+	id, err := g.resolver.ResolveShardID()
+	if err != nil {
+		return 0, err
+	}
+	g.id, g.resolved = id, true
+	return id, nil
+}
+
+// GetNumber returns shard id, it panics if shard id cannot be resolved
+func (g *LazyShardIDGetter) GetNumber() int64 {
+	id, err := g.ResolveShardID()
+	if err != nil {
+		log.Panic(err)
+	}
+	return id
+}
+
+// FileShardIDLease acquires a shard id by creating a lease file named shard-<id>.lease in a shared directory.
+// While held, the lease file is touched periodically. A lease file which hasn't been touched for ttl is considered
+// expired and may be taken over by another process. Taking over expired leases is best-effort.
+type FileShardIDLease struct {
+	dir       string
+	numShards int64
+	ttl       time.Duration
+
+	mu   sync.Mutex
+	file string
+	id   int64
+	stop chan struct{}
+}
+
+var _ ShardIDResolver = (*FileShardIDLease)(nil)
+
+// NewFileShardIDLease creates a file lease of numShards shards in dir. numShards must fit in shardBitSize bits.
+// ttl <= 0 means leases never expire
+func NewFileShardIDLease(dir string, numShards int64, shardBitSize uint, ttl time.Duration) (*FileShardIDLease, error) {
+	if err := checkNumShards(numShards, shardBitSize); err != nil {
+		return nil, err
+	}
+	return &FileShardIDLease{
+		dir:       dir,
+		numShards: numShards,
+		ttl:       ttl,
+	}, nil
+}
+
+// ResolveShardID acquires the first available shard id, or returns the held one
+func (l *FileShardIDLease) ResolveShardID() (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != "" {
+		return l.id, nil
+	}
+
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return 0, fmt.Errorf("make dir %s: %w", l.dir, err)
+	}
+
+	for id := int64(0); id < l.numShards; id++ {
+		name := filepath.Join(l.dir, fmt.Sprintf("shard-%d.lease", id))
+		ok, err := l.tryAcquire(name)
+		if err != nil {
+			return 0, fmt.Errorf("acquire %s: %w", name, err)
+		}
+		if ok {
+			l.file = name
+			l.id = id
+			if l.ttl > 0 {
+				l.stop = make(chan struct{})
+				go l.renew(name, l.stop)
+			}
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("all %d shards are leased", l.numShards)
+}
+
+func (l *FileShardIDLease) tryAcquire(name string) (bool, error) {
+	ok, err := createLeaseFile(name)
+	if ok || err != nil || l.ttl <= 0 {
+		return ok, err
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return createLeaseFile(name)
+		}
+		return false, err
+	}
+
+	if time.Since(fi.ModTime()) < l.ttl {
+		return false, nil
+	}
+
+	if err = os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+	return createLeaseFile(name)
+}
+
+func createLeaseFile(name string) (bool, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	host, _ := os.Hostname()
+	_, err = fmt.Fprintf(f, "%s %d %s\n", host, os.Getpid(), time.Now().Format(time.RFC3339))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name)
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *FileShardIDLease) renew(name string, stop chan struct{}) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := time.Now()
+			if err := os.Chtimes(name, now, now); err != nil {
+				log.Errorf("Renew lease %s: %v", name, err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Release stops renewing and removes the lease file
+func (l *FileShardIDLease) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == "" {
+		return nil
+	}
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+	name := l.file
+	l.file = ""
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// resolverNumberGetter adapts ShardIDResolver to NumberGetter
+type resolverNumberGetter struct {
+	ShardIDResolver
+}
+
+func (g *resolverNumberGetter) GetNumber() int64 {
+	id, err := g.ResolveShardID()
+	if err != nil {
+		log.Panic(err)
+	}
+	return id
+}
@@ -0,0 +1,366 @@
+package gox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gopub/gox/sql"
+	"github.com/gopub/log"
+)
+
+const (
+	ErrShardLeaseLost     ErrorString = "shard lease lost"
+	ErrNoShardAvailable   ErrorString = "no shard available"
+	ErrShardLeaseNotOwned ErrorString = "shard lease not owned"
+)
+
+// ShardCoordinator leases shard ids to processes across a fleet, so that no two processes share a shard id
+type ShardCoordinator interface {
+	// Acquire leases a free or expired shard id to owner for ttl. If owner holds an unexpired lease, it's renewed and returned
+	Acquire(owner string, ttl time.Duration) (int64, error)
+	// Renew extends owner's lease of shardID by ttl, returns ErrShardLeaseLost if the lease has expired or been taken over
+	Renew(shardID int64, owner string, ttl time.Duration) error
+	// Release gives up owner's lease of shardID
+	Release(shardID int64, owner string) error
+}
+
+// ShardLease holds a shard id leased from ShardCoordinator and renews it periodically.
+// Once the lease is lost, ResolveShardID returns ErrShardLeaseLost, hence SnakeIDGenerator refuses to issue ids.
+type ShardLease struct {
+	coordinator ShardCoordinator
+	owner       string
+	ttl         time.Duration
+
+	now func() time.Time
+
+	mu        sync.Mutex
+	acquired  bool
+	shardID   int64
+	expiresAt time.Time
+	err       error
+	stop      chan struct{}
+}
+
+var _ ShardIDResolver = (*ShardLease)(nil)
+var _ NumberGetter = (*ShardLease)(nil)
+
+// NewShardLease creates a lease which is acquired on first use. If owner is empty, hostname and pid are used
+func NewShardLease(coordinator ShardCoordinator, owner string, ttl time.Duration) *ShardLease {
+	if coordinator == nil {
+		panic("coordinator is nil")
+	}
+	if ttl <= 0 {
+		panic("ttl should be positive")
+	}
+	if owner == "" {
+		host, _ := os.Hostname()
+		owner = fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+	}
+	return &ShardLease{
+		coordinator: coordinator,
+		owner:       owner,
+		ttl:         ttl,
+		now:         time.Now,
+	}
+}
+
+func (l *ShardLease) Owner() string {
+	return l.owner
+}
+
+// ResolveShardID acquires a shard id on first call, and returns ErrShardLeaseLost after the lease is lost
+func (l *ShardLease) ResolveShardID() (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return 0, l.err
+	}
+
+	if !l.acquired {
+		start := l.now()
+		id, err := l.coordinator.Acquire(l.owner, l.ttl)
+		if err != nil {
+			return 0, fmt.Errorf("acquire shard: %w", err)
+		}
+		l.acquired = true
+		l.shardID = id
+		l.expiresAt = start.Add(l.ttl)
+		l.stop = make(chan struct{})
+		go l.heartbeat(l.stop)
+		return id, nil
+	}
+
+	if !l.now().Before(l.expiresAt) {
+		l.loseLocked()
+		return 0, l.err
+	}
+	return l.shardID, nil
+}
+
+// GetNumber returns shard id, it panics if the lease cannot be acquired or has been lost
+func (l *ShardLease) GetNumber() int64 {
+	id, err := l.ResolveShardID()
+	if err != nil {
+		log.Panic(err)
+	}
+	return id
+}
+
+// Release stops heartbeat and releases the lease. The lease cannot be used after being released
+func (l *ShardLease) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.acquired || l.err != nil {
+		l.err = ErrShardLeaseLost
+		return nil
+	}
+	l.loseLocked()
+	return l.coordinator.Release(l.shardID, l.owner)
+}
+
+func (l *ShardLease) loseLocked() {
+	l.err = ErrShardLeaseLost
+	if l.stop != nil {
+		close(l.stop)
+		l.stop = nil
+	}
+}
+
+func (l *ShardLease) heartbeat(stop chan struct{}) {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.renew()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (l *ShardLease) renew() {
+	l.mu.Lock()
+	shardID, lost := l.shardID, l.err != nil
+	l.mu.Unlock()
+	if lost {
+		return
+	}
+
+	start := l.now()
+	err := l.coordinator.Renew(shardID, l.owner, l.ttl)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return
+	}
+	switch {
+	case err == nil:
+		l.expiresAt = start.Add(l.ttl)
+	case errors.Is(err, ErrShardLeaseLost):
+		log.Errorf("Lost lease of shard %d", l.shardID)
+		l.loseLocked()
+	default:
+		// keep trying until expiresAt, ResolveShardID fails after that
+		log.Errorf("Renew lease of shard %d: %v", l.shardID, err)
+	}
+}
+
+type shardLeaseEntry struct {
+	owner     string
+	expiresAt time.Time
+}
+
+// MemoryShardCoordinator is an in-memory ShardCoordinator, it can coordinate generators within a process or be used in tests
+type MemoryShardCoordinator struct {
+	numShards int64
+	now       func() time.Time
+
+	mu     sync.Mutex
+	leases map[int64]*shardLeaseEntry
+}
+
+var _ ShardCoordinator = (*MemoryShardCoordinator)(nil)
+
+// NewMemoryShardCoordinator creates a coordinator of numShards shard ids, which must fit in shardBitSize bits
+func NewMemoryShardCoordinator(numShards int64, shardBitSize uint) (*MemoryShardCoordinator, error) {
+	if err := checkNumShards(numShards, shardBitSize); err != nil {
+		return nil, err
+	}
+	return &MemoryShardCoordinator{
+		numShards: numShards,
+		now:       time.Now,
+		leases:    make(map[int64]*shardLeaseEntry, numShards),
+	}, nil
+}
+
+func checkNumShards(numShards int64, shardBitSize uint) error {
+	if numShards <= 0 {
+		return fmt.Errorf("invalid numShards %d", numShards)
+	}
+	if shardBitSize > 8 {
+		return fmt.Errorf("invalid shardBitSize %d", shardBitSize)
+	}
+	if numShards > 1<<shardBitSize {
+		return fmt.Errorf("numShards %d exceeds %d shards of %d bits", numShards, 1<<shardBitSize, shardBitSize)
+	}
+	return nil
+}
+
+func (c *MemoryShardCoordinator) Acquire(owner string, ttl time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for id, e := range c.leases {
+		if e.owner == owner && now.Before(e.expiresAt) {
+			e.expiresAt = now.Add(ttl)
+			return id, nil
+		}
+	}
+	for id := int64(0); id < c.numShards; id++ {
+		e := c.leases[id]
+		if e == nil || !now.Before(e.expiresAt) {
+			c.leases[id] = &shardLeaseEntry{
+				owner:     owner,
+				expiresAt: now.Add(ttl),
+			}
+			return id, nil
+		}
+	}
+	return 0, ErrNoShardAvailable
+}
+
+func (c *MemoryShardCoordinator) Renew(shardID int64, owner string, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	e := c.leases[shardID]
+	if e == nil || e.owner != owner || !now.Before(e.expiresAt) {
+		return ErrShardLeaseLost
+	}
+	e.expiresAt = now.Add(ttl)
+	return nil
+}
+
+func (c *MemoryShardCoordinator) Release(shardID int64, owner string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := c.leases[shardID]
+	if e == nil || e.owner != owner {
+		return ErrShardLeaseNotOwned
+	}
+	delete(c.leases, shardID)
+	return nil
+}
+
+// SQLShardCoordinator is a ShardCoordinator backed by a table with columns: id, owner and expires_at(unix milliseconds).
+// Statements are written in PostgreSQL dialect. Expiration is based on the clocks of coordinated processes.
+type SQLShardCoordinator struct {
+	db        sql.Executor
+	table     string
+	numShards int64
+}
+
+var _ ShardCoordinator = (*SQLShardCoordinator)(nil)
+
+// NewSQLShardCoordinator creates a coordinator of numShards shard ids, which must fit in shardBitSize bits
+func NewSQLShardCoordinator(db sql.Executor, table string, numShards int64, shardBitSize uint) (*SQLShardCoordinator, error) {
+	if db == nil {
+		return nil, errors.New("db is nil")
+	}
+	if table == "" {
+		return nil, errors.New("table is empty")
+	}
+	if err := checkNumShards(numShards, shardBitSize); err != nil {
+		return nil, err
+	}
+	return &SQLShardCoordinator{
+		db:        db,
+		table:     table,
+		numShards: numShards,
+	}, nil
+}
+
+// CreateTable creates the lease table if it doesn't exist
+func (c *SQLShardCoordinator) CreateTable() error {
+	_, err := c.db.Exec(fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s(
+id BIGINT PRIMARY KEY,
+owner VARCHAR(256) NOT NULL,
+expires_at BIGINT NOT NULL
+)`, c.table))
+	if err != nil {
+		return fmt.Errorf("create table %s: %w", c.table, err)
+	}
+	return nil
+}
+
+func (c *SQLShardCoordinator) Acquire(owner string, ttl time.Duration) (int64, error) {
+	for id := int64(0); id < c.numShards; id++ {
+		err := c.Renew(id, owner, ttl)
+		if err == nil {
+			return id, nil
+		}
+		if !errors.Is(err, ErrShardLeaseLost) {
+			return 0, err
+		}
+	}
+	now := time.Now()
+	nowMS := toMilliseconds(now)
+	expiresAt := toMilliseconds(now.Add(ttl))
+	for id := int64(0); id < c.numShards; id++ {
+		res, err := c.db.Exec(fmt.Sprintf(`INSERT INTO %s(id,owner,expires_at) VALUES($1,$2,$3) ON CONFLICT (id) DO UPDATE
+SET owner=EXCLUDED.owner,expires_at=EXCLUDED.expires_at WHERE %s.expires_at<=$4 OR %s.owner=EXCLUDED.owner`,
+			c.table, c.table, c.table), id, owner, expiresAt, nowMS)
+		if err != nil {
+			return 0, fmt.Errorf("lease shard %d: %w", id, err)
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, fmt.Errorf("get rows affected: %w", err)
+		}
+		if n > 0 {
+			return id, nil
+		}
+	}
+	return 0, ErrNoShardAvailable
+}
+
+func (c *SQLShardCoordinator) Renew(shardID int64, owner string, ttl time.Duration) error {
+	now := time.Now()
+	res, err := c.db.Exec(fmt.Sprintf(`UPDATE %s SET expires_at=$1 WHERE id=$2 AND owner=$3 AND expires_at>$4`, c.table),
+		toMilliseconds(now.Add(ttl)), shardID, owner, toMilliseconds(now))
+	if err != nil {
+		return fmt.Errorf("renew shard %d: %w", shardID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrShardLeaseLost
+	}
+	return nil
+}
+
+func (c *SQLShardCoordinator) Release(shardID int64, owner string) error {
+	res, err := c.db.Exec(fmt.Sprintf(`DELETE FROM %s WHERE id=$1 AND owner=$2`, c.table), shardID, owner)
+	if err != nil {
+		return fmt.Errorf("release shard %d: %w", shardID, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("get rows affected: %w", err)
+	}
+	if n == 0 {
+		return ErrShardLeaseNotOwned
+	}
+	return nil
+}
+
+func toMilliseconds(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
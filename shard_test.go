@@ -0,0 +1,328 @@
+package gox_test
+
+import (
+	stdsql "database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gopub/gox"
+	"github.com/gopub/gox/sql"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShardIDByEnv(t *testing.T) {
+	const key = "GOX_TEST_SHARD_ID"
+	os.Unsetenv(key)
+	_, err := gox.ShardIDByEnv(key).ResolveShardID()
+	require.Error(t, err)
+
+	os.Setenv(key, "5")
+	defer os.Unsetenv(key)
+	id, err := gox.ShardIDByEnv(key).ResolveShardID()
+	require.NoError(t, err)
+	require.Equal(t, int64(5), id)
+
+	id, err = gox.ChainShardIDResolvers(gox.ShardIDByEnv("GOX_TEST_NO_SHARD_ID"), gox.ShardIDByEnv(key)).ResolveShardID()
+	require.NoError(t, err)
+	require.Equal(t, int64(5), id)
+}
+
+func TestLazyShardIDGetter(t *testing.T) {
+	calls := 0
+	g := gox.NewLazyShardIDGetter(gox.ShardIDResolverFunc(func() (int64, error) {
+		calls++
+		if calls == 1 {
+			return 0, errors.New("network is unreachable")
+		}
+		return int64(calls), nil
+	}))
+	_, err := g.ResolveShardID()
+	require.Error(t, err)
+	id, err := g.ResolveShardID()
+	require.NoError(t, err)
+	require.Equal(t, int64(2), id)
+	require.Equal(t, int64(2), g.GetNumber())
+	require.Equal(t, 2, calls)
+}
+
+func newFileShardIDLease(t *testing.T, dir string) *gox.FileShardIDLease {
+	l, err := gox.NewFileShardIDLease(dir, 2, gox.DefaultShardBitSize, time.Minute)
+	require.NoError(t, err)
+	return l
+}
+
+func TestFileShardIDLease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gox_shard")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l1 := newFileShardIDLease(t, dir)
+	l2 := newFileShardIDLease(t, dir)
+	l3 := newFileShardIDLease(t, dir)
+
+	id1, err := l1.ResolveShardID()
+	require.NoError(t, err)
+	id2, err := l2.ResolveShardID()
+	require.NoError(t, err)
+	require.NotEqual(t, id1, id2)
+
+	_, err = l3.ResolveShardID()
+	require.Error(t, err)
+
+	require.NoError(t, l1.Release())
+	id3, err := l3.ResolveShardID()
+	require.NoError(t, err)
+	require.Equal(t, id1, id3)
+	require.NoError(t, l2.Release())
+	require.NoError(t, l3.Release())
+}
+
+func TestFileShardIDLease_DefaultResolver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gox_shard")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	l := newFileShardIDLease(t, dir)
+	defer l.Release()
+	resolver := gox.DefaultShardIDResolver
+	gox.DefaultShardIDResolver = l
+	defer func() { gox.DefaultShardIDResolver = resolver }()
+
+	id1 := gox.NextID()
+	id2 := gox.NextID()
+	require.NotEqual(t, id1, id2)
+	shardID, err := l.ResolveShardID()
+	require.NoError(t, err)
+	require.Equal(t, int64(0), shardID)
+}
+
+func TestShardLease(t *testing.T) {
+	c, err := gox.NewMemoryShardCoordinator(2, gox.DefaultShardBitSize)
+	require.NoError(t, err)
+	l1 := gox.NewShardLease(c, "p1", time.Minute)
+	l2 := gox.NewShardLease(c, "p2", time.Minute)
+	l3 := gox.NewShardLease(c, "p3", time.Minute)
+
+	id1, err := l1.ResolveShardID()
+	require.NoError(t, err)
+	id2, err := l2.ResolveShardID()
+	require.NoError(t, err)
+	require.NotEqual(t, id1, id2)
+	_, err = l3.ResolveShardID()
+	require.Equal(t, gox.ErrNoShardAvailable, errors.Unwrap(err))
+
+	g := gox.NewSnakeIDGenerator(gox.DefaultShardBitSize, gox.DefaultSeqBitSize, gox.NextMilliseconds, l1, &gox.Counter{})
+	_, err = g.TryNextID()
+	require.NoError(t, err)
+
+	require.NoError(t, l1.Release())
+	_, err = g.TryNextID()
+	require.Equal(t, gox.ErrShardLeaseLost, err)
+
+	id3, err := l3.ResolveShardID()
+	require.NoError(t, err)
+	require.Equal(t, id1, id3)
+}
+
+func TestNewShardCoordinator(t *testing.T) {
+	_, err := gox.NewMemoryShardCoordinator(8, 3)
+	require.NoError(t, err)
+	_, err = gox.NewMemoryShardCoordinator(9, 3)
+	require.Error(t, err)
+	_, err = gox.NewMemoryShardCoordinator(0, 3)
+	require.Error(t, err)
+	_, err = gox.NewSQLShardCoordinator(newFakeLeaseDB("shard_leases"), "shard_leases", 2, 0)
+	require.Error(t, err)
+	_, err = gox.NewFileShardIDLease(os.TempDir(), 3, 1, time.Minute)
+	require.Error(t, err)
+	_, err = gox.NewFileShardIDLease(os.TempDir(), 0, 1, time.Minute)
+	require.Error(t, err)
+}
+
+// wrappingCoordinator wraps errors of Renew like remote coordinators may do
+type wrappingCoordinator struct {
+	gox.ShardCoordinator
+}
+
+func (c *wrappingCoordinator) Renew(shardID int64, owner string, ttl time.Duration) error {
+	if err := c.ShardCoordinator.Renew(shardID, owner, ttl); err != nil {
+		return fmt.Errorf("renew shard %d: %w", shardID, err)
+	}
+	return nil
+}
+
+func TestShardLease_RenewLost(t *testing.T) {
+	mc, err := gox.NewMemoryShardCoordinator(1, gox.DefaultShardBitSize)
+	require.NoError(t, err)
+	l := gox.NewShardLease(&wrappingCoordinator{mc}, "p1", time.Minute)
+	id, err := l.ResolveShardID()
+	require.NoError(t, err)
+	require.NoError(t, mc.Release(id, "p1"))
+	l.Renew()
+	_, err = l.ResolveShardID()
+	require.Equal(t, gox.ErrShardLeaseLost, err)
+}
+
+func TestMemoryShardCoordinator_AcquireOwned(t *testing.T) {
+	c, err := gox.NewMemoryShardCoordinator(3, gox.DefaultShardBitSize)
+	require.NoError(t, err)
+	id0, err := c.Acquire("p0", time.Minute)
+	require.NoError(t, err)
+	id1, err := c.Acquire("p1", time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, c.Release(id0, "p0"))
+
+	// p1 keeps its lease though a lower shard id is free
+	id, err := c.Acquire("p1", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, id1, id)
+	id, err = c.Acquire("p2", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, id0, id)
+}
+
+// testClock is a clock which only moves forward by Add
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+func TestShardLease_Expired(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	c, err := gox.NewMemoryShardCoordinator(1, gox.DefaultShardBitSize)
+	require.NoError(t, err)
+	c.SetClock(clock.Now)
+	l1 := gox.NewShardLease(c, "p1", 30*time.Millisecond)
+	l1.SetClock(clock.Now)
+	_, err = l1.ResolveShardID()
+	require.NoError(t, err)
+
+	// p1 cannot renew once clock passes its expiration, then p2 takes over the shard
+	clock.Add(50 * time.Millisecond)
+	id, err := c.Acquire("p2", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), id)
+	_, err = l1.ResolveShardID()
+	require.Equal(t, gox.ErrShardLeaseLost, err)
+	require.Equal(t, gox.ErrShardLeaseNotOwned, c.Release(0, "p1"))
+}
+
+type fakeLease struct {
+	owner     string
+	expiresAt int64
+}
+
+// fakeLeaseDB executes statements of SQLShardCoordinator on a map
+type fakeLeaseDB struct {
+	table  string
+	leases map[int64]*fakeLease
+}
+
+var _ sql.Executor = (*fakeLeaseDB)(nil)
+
+func newFakeLeaseDB(table string) *fakeLeaseDB {
+	return &fakeLeaseDB{table: table, leases: map[int64]*fakeLease{}}
+}
+
+func (db *fakeLeaseDB) Exec(query string, args ...interface{}) (stdsql.Result, error) {
+	if !strings.Contains(query, db.table) {
+		return nil, fmt.Errorf("unknown table: %s", query)
+	}
+	var n int64
+	switch strings.Fields(query)[0] {
+	case "CREATE":
+	case "INSERT":
+		id, owner, expiresAt, now := args[0].(int64), args[1].(string), args[2].(int64), args[3].(int64)
+		if l := db.leases[id]; l == nil || l.expiresAt <= now || l.owner == owner {
+			db.leases[id] = &fakeLease{owner: owner, expiresAt: expiresAt}
+			n = 1
+		}
+	case "UPDATE":
+		expiresAt, id, owner, now := args[0].(int64), args[1].(int64), args[2].(string), args[3].(int64)
+		if l := db.leases[id]; l != nil && l.owner == owner && l.expiresAt > now {
+			l.expiresAt = expiresAt
+			n = 1
+		}
+	case "DELETE":
+		id, owner := args[0].(int64), args[1].(string)
+		if l := db.leases[id]; l != nil && l.owner == owner {
+			delete(db.leases, id)
+			n = 1
+		}
+	default:
+		return nil, fmt.Errorf("unsupported query: %s", query)
+	}
+	return driver.RowsAffected(n), nil
+}
+
+func (db *fakeLeaseDB) Query(query string, args ...interface{}) (*stdsql.Rows, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (db *fakeLeaseDB) QueryRow(query string, args ...interface{}) *stdsql.Row {
+	return nil
+}
+
+func TestSQLShardCoordinator(t *testing.T) {
+	db := newFakeLeaseDB("shard_leases")
+	c, err := gox.NewSQLShardCoordinator(db, "shard_leases", 2, gox.DefaultShardBitSize)
+	require.NoError(t, err)
+	require.NoError(t, c.CreateTable())
+
+	id1, err := c.Acquire("p1", time.Minute)
+	require.NoError(t, err)
+	id2, err := c.Acquire("p2", time.Minute)
+	require.NoError(t, err)
+	require.NotEqual(t, id1, id2)
+	_, err = c.Acquire("p3", time.Minute)
+	require.Equal(t, gox.ErrNoShardAvailable, err)
+
+	// acquiring again returns the shard already owned
+	id, err := c.Acquire("p1", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, id1, id)
+
+	// p2 keeps its lease though a lower shard id is free
+	require.NoError(t, c.Release(id1, "p1"))
+	id, err = c.Acquire("p2", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, id2, id)
+	id, err = c.Acquire("p1", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, id1, id)
+
+	require.NoError(t, c.Renew(id1, "p1", time.Minute))
+	require.Equal(t, gox.ErrShardLeaseLost, c.Renew(id1, "p2", time.Minute))
+	require.Equal(t, gox.ErrShardLeaseNotOwned, c.Release(id1, "p2"))
+	require.NoError(t, c.Release(id1, "p1"))
+	require.Equal(t, gox.ErrShardLeaseLost, c.Renew(id1, "p1", time.Minute))
+
+	// expired lease is taken over
+	db.leases[id2].expiresAt = time.Now().Add(-time.Second).UnixNano() / int64(time.Millisecond)
+	require.Equal(t, gox.ErrShardLeaseLost, c.Renew(id2, "p2", time.Minute))
+	id, err = c.Acquire("p3", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, id1, id)
+	id, err = c.Acquire("p4", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, id2, id)
+}
@@ -1,48 +1,44 @@
 package gox
 
-func IndexOfInt(a []int, i int) int {
-	for idx, v := range a {
-		if v == i {
-			return idx
+// IndexOf returns index of the first v in a, or -1 if not found
+func IndexOf[T comparable](a []T, v T) int {
+	for i, e := range a {
+		if e == v {
+			return i
 		}
 	}
-
 	return -1
 }
 
-func IndexOfInt64(a []int64, i int64) int {
-	for idx, v := range a {
-		if v == i {
-			return idx
-		}
-	}
+func IndexOfInt(a []int, i int) int {
+	return IndexOf(a, i)
+}
 
-	return -1
+func IndexOfInt64(a []int64, i int64) int {
+	return IndexOf(a, i)
 }
 
 func IndexOfString(a []string, s string) int {
-	for i, str := range a {
-		if str == s {
-			return i
-		}
-	}
-
-	return -1
+	return IndexOf(a, s)
 }
 
-func ReverseIntSlice(s []int) {
+// Reverse reverses s in place
+func Reverse[T any](s []T) {
 	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
 		s[i], s[j] = s[j], s[i]
 	}
 }
 
+func ReverseIntSlice(s []int) {
+	Reverse(s)
+}
+
 func ReverseInt64Slice(s []int64) {
-	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
-		s[i], s[j] = s[j], s[i]
-	}
+	Reverse(s)
 }
 
-func RemoveInt64(s []int64, v int64) []int64 {
+// Remove removes all v in s
+func Remove[T comparable](s []T, v T) []T {
 	for i := len(s) - 1; i >= 0; i-- {
 		if s[i] == v {
 			s = append(s[:i], s[i+1:]...)
@@ -51,20 +47,94 @@ func RemoveInt64(s []int64, v int64) []int64 {
 	return s
 }
 
+func RemoveInt64(s []int64, v int64) []int64 {
+	return Remove(s, v)
+}
+
 func RemoveFloat64(s []float64, v float64) []float64 {
-	for i := len(s) - 1; i >= 0; i-- {
-		if s[i] == v {
-			s = append(s[:i], s[i+1:]...)
+	return Remove(s, v)
+}
+
+func RemoveString(s []string, v string) []string {
+	return Remove(s, v)
+}
+
+// Map returns results of f applied to each element of s
+func Map[T, R any](s []T, f func(T) R) []R {
+	res := make([]R, len(s))
+	for i, v := range s {
+		res[i] = f(v)
+	}
+	return res
+}
+
+// Filter returns elements of s which satisfy f
+func Filter[T any](s []T, f func(T) bool) []T {
+	var res []T
+	for _, v := range s {
+		if f(v) {
+			res = append(res, v)
 		}
 	}
-	return s
+	return res
 }
 
-func RemoveString(s []string, v string) []string {
-	for i := len(s) - 1; i >= 0; i-- {
-		if s[i] == v {
-			s = append(s[:i], s[i+1:]...)
+// Reduce folds s into a single value starting from initial
+func Reduce[T, R any](s []T, initial R, f func(R, T) R) R {
+	res := initial
+	for _, v := range s {
+		res = f(res, v)
+	}
+	return res
+}
+
+// Chunk splits s into chunks of size. The last chunk may be smaller
+func Chunk[T any](s []T, size int) [][]T {
+	if size <= 0 {
+		panic("size must be positive")
+	}
+	res := make([][]T, 0, (len(s)+size-1)/size)
+	for i := 0; i < len(s); i += size {
+		end := i + size
+		if end > len(s) {
+			end = len(s)
 		}
+		res = append(res, s[i:end:end])
 	}
-	return s
+	return res
+}
+
+// Uniq returns unique elements of s in the order of first occurrence
+func Uniq[T comparable](s []T) []T {
+	seen := make(map[T]Void, len(s))
+	res := make([]T, 0, len(s))
+	for _, v := range s {
+		if _, ok := seen[v]; !ok {
+			seen[v] = Void{}
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// GroupBy groups elements of s by key
+func GroupBy[T any, K comparable](s []T, key func(T) K) map[K][]T {
+	res := make(map[K][]T)
+	for _, v := range s {
+		k := key(v)
+		res[k] = append(res[k], v)
+	}
+	return res
+}
+
+// Partition splits s into elements which satisfy f and the others
+func Partition[T any](s []T, f func(T) bool) (matched, unmatched []T) {
+	for _, v := range s {
+		if f(v) {
+			matched = append(matched, v)
+		} else {
+			unmatched = append(unmatched, v)
+		}
+	}
+	return matched, unmatched
 }
@@ -38,3 +38,21 @@ func (m Map) Value() (driver.Value, error) {
 	}
 	return json.Marshal(m)
 }
+
+// MarshalCodec implements codec.Marshaler
+func (m Map) MarshalCodec() (interface{}, error) {
+	return map[string]interface{}(m), nil
+}
+
+// UnmarshalCodec implements codec.Unmarshaler
+func (m *Map) UnmarshalCodec(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		*m = nil
+	case map[string]interface{}:
+		*m = v
+	default:
+		return fmt.Errorf("cannot unmarshal %T into sql.Map", v)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package gox
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// reasonNotExist marks status converted from ErrNotExist
+const reasonNotExist = "gox.not_exist"
+
+// HTTPStatusToCode maps HTTP status to canonical gRPC code.
+// Application codes larger than 999, e.g. 1404, are mapped by code % 1000
+func HTTPStatusToCode(httpStatus int) codes.Code {
+	if httpStatus >= 1000 {
+		httpStatus %= 1000
+	}
+	switch httpStatus {
+	case http.StatusOK:
+		return codes.OK
+	case http.StatusBadRequest:
+		return codes.InvalidArgument
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.NotFound
+	case http.StatusConflict:
+		return codes.AlreadyExists
+	case http.StatusPreconditionFailed:
+		return codes.FailedPrecondition
+	case http.StatusRequestedRangeNotSatisfiable:
+		return codes.OutOfRange
+	case http.StatusTooManyRequests:
+		return codes.ResourceExhausted
+	case 499:
+		return codes.Canceled
+	case http.StatusInternalServerError:
+		return codes.Internal
+	case http.StatusNotImplemented:
+		return codes.Unimplemented
+	case http.StatusServiceUnavailable:
+		return codes.Unavailable
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	}
+	switch {
+	case httpStatus >= 200 && httpStatus < 300:
+		return codes.OK
+	case httpStatus >= 400 && httpStatus < 500:
+		return codes.FailedPrecondition
+	case httpStatus >= 500 && httpStatus < 600:
+		return codes.Internal
+	default:
+		return codes.Unknown
+	}
+}
+
+// CodeToHTTPStatus maps canonical gRPC code to HTTP status
+func CodeToHTTPStatus(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ToStatusError converts err to gRPC status error with canonical code.
+// *Error is carried in details so that FromStatusError restores it exactly,
+// and its details are also attached as standard google.rpc error details
+func ToStatusError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if !errors.As(err, &e) {
+		if _, ok := status.FromError(Cause(err)); ok {
+			// if err is status error, return directly
+			return Cause(err)
+		}
+		e = ToError(err)
+	}
+
+	code := HTTPStatusToCode(e.HTTPStatus())
+	s, err := status.New(code, e.Message).WithDetails(standardErrorDetails(e)...)
+	if err != nil {
+		return status.Error(code, e.Message)
+	}
+	pa, err := NewAny(e).ToProto()
+	if err != nil {
+		return s.Err()
+	}
+	p := s.Proto()
+	p.Details = append(p.Details, pa)
+	return status.ErrorProto(p)
+}
+
+func standardErrorDetails(e *Error) []proto.Message {
+	var details []proto.Message
+	if violations := e.FieldViolations(); len(violations) > 0 {
+		br := &errdetails.BadRequest{}
+		for _, v := range violations {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       v.Field,
+				Description: v.Description,
+			})
+		}
+		details = append(details, br)
+	}
+	for _, d := range e.Details {
+		switch v := d.Val().(type) {
+		case *RetryInfo:
+			details = append(details, &errdetails.RetryInfo{RetryDelay: ptypes.DurationProto(v.RetryDelay)})
+		case *LocalizedMessage:
+			details = append(details, &errdetails.LocalizedMessage{Locale: v.Locale, Message: v.Message})
+		}
+	}
+	if len(e.Stack) > 0 {
+		details = append(details, &errdetails.DebugInfo{StackEntries: e.Stack})
+	}
+	return details
+}
+
+// ToError classifies err into *Error. ErrNotExist and sql.ErrNoRows are not found,
+// gRPC status errors are converted by FromStatusError, and others are internal errors
+func ToError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	if errors.Is(err, ErrNotExist) || errors.Is(err, sql.ErrNoRows) {
+		return NotFound("%s", ErrNotExist.Error()).WithReason(reasonNotExist)
+	}
+	if _, ok := status.FromError(Cause(err)); ok {
+		if errors.As(FromStatusError(Cause(err)), &e) {
+			return e
+		}
+		return NotFound("%s", ErrNotExist.Error()).WithReason(reasonNotExist)
+	}
+	return InternalError("%s", err.Error())
+}
+
+// FromStatusError converts gRPC status error to *Error, or ErrNotExist.
+// Errors created by ToStatusError are restored exactly, otherwise code and standard details are converted
+func FromStatusError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	if s.Code() == codes.OK {
+		return nil
+	}
+
+	for _, pa := range s.Proto().Details {
+		if pa.TypeUrl != AnyTypeURL(&Error{}) {
+			continue
+		}
+		a, aerr := NewAnyFromProto(pa)
+		if aerr != nil {
+			break
+		}
+		if e, ok := a.Val().(*Error); ok {
+			if e.Reason == reasonNotExist {
+				return ErrNotExist
+			}
+			return e
+		}
+	}
+
+	code := CodeToHTTPStatus(s.Code())
+	if int(s.Code()) >= 100 {
+		// legacy servers use http status as code directly
+		code = int(s.Code())
+		if code == http.StatusNotFound && s.Message() == ErrNotExist.Error() {
+			return ErrNotExist
+		}
+	}
+	e := &Error{
+		Code:    code,
+		Message: s.Message(),
+	}
+	for _, d := range s.Details() {
+		switch v := d.(type) {
+		case *errdetails.BadRequest:
+			violations := make([]*FieldViolation, len(v.FieldViolations))
+			for i, fv := range v.FieldViolations {
+				violations[i] = &FieldViolation{Field: fv.Field, Description: fv.Description}
+			}
+			e.WithFieldViolations(violations...)
+		case *errdetails.RetryInfo:
+			if d, derr := ptypes.Duration(v.RetryDelay); derr == nil {
+				e.WithRetryDelay(d)
+			}
+		case *errdetails.LocalizedMessage:
+			e.WithLocalizedMessage(v.Locale, v.Message)
+		case *errdetails.DebugInfo:
+			e.Stack = v.StackEntries
+		}
+	}
+	return e
+}
@@ -0,0 +1,158 @@
+package gox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/wrappers"
+	"github.com/gopub/gox"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestHTTPStatusToCode(t *testing.T) {
+	for httpStatus, code := range map[int]codes.Code{
+		http.StatusBadRequest:          codes.InvalidArgument,
+		http.StatusUnauthorized:        codes.Unauthenticated,
+		http.StatusForbidden:           codes.PermissionDenied,
+		http.StatusNotFound:            codes.NotFound,
+		http.StatusConflict:            codes.AlreadyExists,
+		http.StatusTooManyRequests:     codes.ResourceExhausted,
+		http.StatusInternalServerError: codes.Internal,
+		http.StatusNotImplemented:      codes.Unimplemented,
+		http.StatusServiceUnavailable:  codes.Unavailable,
+		http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+	} {
+		require.Equal(t, code, gox.HTTPStatusToCode(httpStatus))
+		require.Equal(t, httpStatus, gox.CodeToHTTPStatus(code))
+	}
+	require.Equal(t, codes.NotFound, gox.HTTPStatusToCode(1404))
+	require.Equal(t, codes.FailedPrecondition, gox.HTTPStatusToCode(http.StatusGone))
+}
+
+// errorServer returns errors[req.Value] from /gox.test.Errors/Get
+type errorServer struct {
+	errors map[string]error
+}
+
+func (s *errorServer) serviceDesc() *grpc.ServiceDesc {
+	return &grpc.ServiceDesc{
+		ServiceName: "gox.test.Errors",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{{
+			MethodName: "Get",
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+				req := new(wrappers.StringValue)
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				return nil, gox.ToStatusError(s.errors[req.Value])
+			},
+		}},
+	}
+}
+
+func TestStatusError_RoundTrip(t *testing.T) {
+	appErr := gox.NewError(1404, "user not found").
+		WithReason("USER_NOT_FOUND").
+		WithCause(errors.New("no rows")).
+		WithFieldViolations(&gox.FieldViolation{Field: "id", Description: "unknown"}).
+		WithRetryDelay(time.Second).
+		WithLocalizedMessage("zh-CN", "用户不存在").
+		WithStack()
+	srv := &errorServer{errors: map[string]error{
+		"app":       appErr,
+		"wrapped":   errors.New("plain failure"),
+		"not_exist": gox.ErrNotExist,
+		"status":    status.Error(codes.Aborted, "aborted"),
+	}}
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	server.RegisterService(srv.serviceDesc(), srv)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	call := func(key string) error {
+		return conn.Invoke(context.Background(), "/gox.test.Errors/Get", &wrappers.StringValue{Value: key}, new(wrappers.StringValue))
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		err := call("app")
+		// standard clients see canonical code and details
+		s := status.Convert(err)
+		require.Equal(t, codes.NotFound, s.Code())
+		require.Equal(t, "user not found", s.Message())
+		var br *errdetails.BadRequest
+		for _, d := range s.Details() {
+			if v, ok := d.(*errdetails.BadRequest); ok {
+				br = v
+			}
+		}
+		require.NotNil(t, br)
+		require.Equal(t, "id", br.FieldViolations[0].Field)
+
+		var e *gox.Error
+		require.True(t, errors.As(gox.FromStatusError(err), &e))
+		expected, err := json.Marshal(appErr)
+		require.NoError(t, err)
+		actual, err := json.Marshal(e)
+		require.NoError(t, err)
+		require.JSONEq(t, string(expected), string(actual))
+		require.EqualError(t, errors.Unwrap(e), "no rows")
+	})
+
+	t.Run("Internal", func(t *testing.T) {
+		err := call("wrapped")
+		require.Equal(t, codes.Internal, status.Code(err))
+		var e *gox.Error
+		require.True(t, errors.As(gox.FromStatusError(err), &e))
+		require.Equal(t, http.StatusInternalServerError, e.Code)
+		require.Equal(t, "plain failure", e.Message)
+	})
+
+	t.Run("NotExist", func(t *testing.T) {
+		err := call("not_exist")
+		require.Equal(t, codes.NotFound, status.Code(err))
+		require.Equal(t, gox.ErrNotExist, gox.FromStatusError(err))
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		err := call("status")
+		require.Equal(t, codes.Aborted, status.Code(err))
+		var e *gox.Error
+		require.True(t, errors.As(gox.FromStatusError(err), &e))
+		require.Equal(t, http.StatusConflict, e.Code)
+	})
+
+	t.Run("Foreign", func(t *testing.T) {
+		s, err := status.New(codes.InvalidArgument, "bad").WithDetails(&errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{{Field: "name", Description: "empty"}},
+		})
+		require.NoError(t, err)
+		var e *gox.Error
+		require.True(t, errors.As(gox.FromStatusError(s.Err()), &e))
+		require.Equal(t, http.StatusBadRequest, e.Code)
+		require.Equal(t, []*gox.FieldViolation{{Field: "name", Description: "empty"}}, e.FieldViolations())
+	})
+
+	t.Run("Legacy", func(t *testing.T) {
+		err := status.Error(codes.Code(http.StatusNotFound), gox.ErrNotExist.Error())
+		require.Equal(t, gox.ErrNotExist, gox.FromStatusError(err))
+	})
+}
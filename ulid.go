@@ -0,0 +1,166 @@
+package gox
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const ErrInvalidULID ErrorString = "invalid ulid"
+
+// ULID is an universally unique lexicographically sortable identifier, see https://github.com/ulid/spec
+// It's composed of 48-bit timestamp in milliseconds and 80-bit randomness, and encoded in Crockford's base32
+type ULID [16]byte
+
+const ulidEncodedSize = 26
+
+var _ sql.Scanner = (*ULID)(nil)
+var _ driver.Valuer = ULID{}
+var _ encoding.TextMarshaler = ULID{}
+var _ encoding.TextUnmarshaler = (*ULID)(nil)
+
+var ulidGenerator = &ulidGen{}
+
+// NewULID returns a new ULID. ULIDs generated within the same millisecond by the same process are monotonically increasing
+func NewULID() ULID {
+	return ulidGenerator.next()
+}
+
+type ulidGen struct {
+	mu     sync.Mutex
+	lastMS int64
+	last   ULID
+}
+
+func (g *ulidGen) next() ULID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+	if ms > g.lastMS {
+		g.lastMS = ms
+		readRandom(g.last[6:])
+	} else if !g.last.increaseRandom() {
+		// randomness overflows, borrow next millisecond
+		g.lastMS++
+		readRandom(g.last[6:])
+	}
+	for i := 0; i < 6; i++ {
+		g.last[i] = byte(g.lastMS >> uint(40-8*i))
+	}
+	return g.last
+}
+
+// increaseRandom increases the 80-bit randomness by 1, returns false on overflow
+func (u *ULID) increaseRandom() bool {
+	for i := len(u) - 1; i >= 6; i-- {
+		u[i]++
+		if u[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Time returns the creation time
+func (u ULID) Time() time.Time {
+	var ms int64
+	for i := 0; i < 6; i++ {
+		ms = ms<<8 | int64(u[i])
+	}
+	return time.Unix(ms/1e3, (ms%1e3)*int64(time.Millisecond))
+}
+
+func (u ULID) String() string {
+	const symbols = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	var b [ulidEncodedSize]byte
+	// 128 bits are encoded from the lowest 5 bits, the first symbol only takes 3 bits
+	var acc uint16
+	var bits uint
+	n := ulidEncodedSize
+	for i := len(u) - 1; i >= 0; i-- {
+		acc |= uint16(u[i]) << bits
+		bits += 8
+		for bits >= 5 {
+			n--
+			b[n] = symbols[acc&0x1f]
+			acc >>= 5
+			bits -= 5
+		}
+	}
+	b[0] = symbols[acc&0x1f]
+	return string(b[:])
+}
+
+// ParseULID parses s in Crockford's base32, it's case insensitive
+func ParseULID(s string) (ULID, error) {
+	var u ULID
+	if len(s) != ulidEncodedSize {
+		return u, fmt.Errorf("%w: %s", ErrInvalidULID, s)
+	}
+	var acc uint16
+	var bits uint
+	n := len(u)
+	for i := ulidEncodedSize - 1; i >= 0; i-- {
+		v := CrockfordBase32Alphabet.values[s[i]]
+		if v < 0 || v >= 32 || (i == 0 && v > 7) {
+			return u, fmt.Errorf("%w: %s", ErrInvalidULID, s)
+		}
+		acc |= uint16(v) << bits
+		bits += 5
+		if bits >= 8 {
+			n--
+			u[n] = byte(acc)
+			acc >>= 8
+			bits -= 8
+		}
+	}
+	return u, nil
+}
+
+func (u ULID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText parses text in form of ULID or UUID
+func (u *ULID) UnmarshalText(text []byte) error {
+	if len(text) != ulidEncodedSize {
+		id, err := ParseUUID(string(text))
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidULID, text)
+		}
+		*u = ULID(id)
+		return nil
+	}
+	v, err := ParseULID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
+
+func (u *ULID) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*u = ULID{}
+		return nil
+	case string:
+		return u.UnmarshalText([]byte(v))
+	case []byte:
+		if len(v) == len(u) {
+			copy(u[:], v)
+			return nil
+		}
+		return u.UnmarshalText(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ULID", src)
+	}
+}
+
+// Value returns ULID in form of UUID, so that it can be stored in uuid columns
+func (u ULID) Value() (driver.Value, error) {
+	return UUID(u).String(), nil
+}
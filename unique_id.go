@@ -42,6 +42,7 @@ func UniqueID() string {
 }
 
 // UniqueID32 returns an unique id of 32 letters
+// Please use NewUUID, NewUUIDv7 or NewULID for standard unique ids
 func UniqueID32() string {
 	return MD5(randomString())
 }
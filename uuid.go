@@ -0,0 +1,196 @@
+package gox
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopub/log"
+)
+
+const ErrInvalidUUID ErrorString = "invalid uuid"
+
+// UUID is an universally unique identifier defined in RFC 9562
+type UUID [16]byte
+
+// NilUUID is the UUID with all bits set to zero
+var NilUUID UUID
+
+var _ sql.Scanner = (*UUID)(nil)
+var _ driver.Valuer = UUID{}
+var _ encoding.TextMarshaler = UUID{}
+var _ encoding.TextUnmarshaler = (*UUID)(nil)
+
+// NewUUID returns a random UUID of version 4
+func NewUUID() UUID {
+	var u UUID
+	readRandom(u[:])
+	u.setVersion(4)
+	return u
+}
+
+var uuidV7Generator = &uuidV7Gen{}
+
+// NewUUIDv7 returns a time-ordered UUID of version 7.
+// UUIDs generated by the same process are monotonically increasing.
+func NewUUIDv7() UUID {
+	return uuidV7Generator.next()
+}
+
+// uuidV7Gen uses rand_a as a 12-bit counter within the same millisecond
+type uuidV7Gen struct {
+	mu      sync.Mutex
+	lastMS  int64
+	counter uint16
+}
+
+func (g *uuidV7Gen) next() UUID {
+	var u UUID
+	readRandom(u[6:])
+
+	g.mu.Lock()
+	ms := time.Now().UnixNano() / int64(time.Millisecond)
+	if ms > g.lastMS {
+		g.lastMS = ms
+		// leave the highest bit clear so that counter can grow
+		g.counter = binary.BigEndian.Uint16(u[6:]) & 0x07ff
+	} else {
+		g.counter++
+		if g.counter > 0x0fff {
+			g.lastMS++
+			g.counter = 0
+		}
+	}
+	ms, counter := g.lastMS, g.counter
+	g.mu.Unlock()
+
+	u[0] = byte(ms >> 40)
+	u[1] = byte(ms >> 32)
+	u[2] = byte(ms >> 24)
+	u[3] = byte(ms >> 16)
+	u[4] = byte(ms >> 8)
+	u[5] = byte(ms)
+	binary.BigEndian.PutUint16(u[6:], counter)
+	u.setVersion(7)
+	return u
+}
+
+func (u *UUID) setVersion(v byte) {
+	u[6] = (u[6] & 0x0f) | v<<4
+	u[8] = (u[8] & 0x3f) | 0x80
+}
+
+func readRandom(b []byte) {
+	if _, err := rand.Read(b); err != nil {
+		log.Panicf("Read random bytes: %v", err)
+	}
+}
+
+// ParseUUID parses s in forms of xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx, {xxxxxxxx-...}, urn:uuid:xxxxxxxx-... or 32 hex digits
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	switch len(s) {
+	case 36:
+	case 38:
+		if s[0] != '{' || s[37] != '}' {
+			return u, fmt.Errorf("%w: %s", ErrInvalidUUID, s)
+		}
+		s = s[1:37]
+	case 45:
+		if !strings.EqualFold(s[:9], "urn:uuid:") {
+			return u, fmt.Errorf("%w: %s", ErrInvalidUUID, s)
+		}
+		s = s[9:]
+	case 32:
+		if _, err := hex.Decode(u[:], []byte(s)); err != nil {
+			return u, fmt.Errorf("%w: %s", ErrInvalidUUID, s)
+		}
+		return u, nil
+	default:
+		return u, fmt.Errorf("%w: %s", ErrInvalidUUID, s)
+	}
+
+	if s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("%w: %s", ErrInvalidUUID, s)
+	}
+	b := []byte(s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:])
+	if _, err := hex.Decode(u[:], b); err != nil {
+		return u, fmt.Errorf("%w: %s", ErrInvalidUUID, s)
+	}
+	return u, nil
+}
+
+func (u UUID) String() string {
+	var b [36]byte
+	hex.Encode(b[0:8], u[0:4])
+	b[8] = '-'
+	hex.Encode(b[9:13], u[4:6])
+	b[13] = '-'
+	hex.Encode(b[14:18], u[6:8])
+	b[18] = '-'
+	hex.Encode(b[19:23], u[8:10])
+	b[23] = '-'
+	hex.Encode(b[24:], u[10:])
+	return string(b[:])
+}
+
+func (u UUID) Version() int {
+	return int(u[6] >> 4)
+}
+
+func (u UUID) IsNil() bool {
+	return u == NilUUID
+}
+
+// Time returns the creation time of version 7 UUID, or zero time for other versions
+func (u UUID) Time() time.Time {
+	if u.Version() != 7 {
+		return time.Time{}
+	}
+	var b [8]byte
+	copy(b[2:], u[:6])
+	ms := int64(binary.BigEndian.Uint64(b[:]))
+	return time.Unix(ms/1e3, (ms%1e3)*int64(time.Millisecond))
+}
+
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+func (u *UUID) UnmarshalText(text []byte) error {
+	v, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = v
+	return nil
+}
+
+func (u *UUID) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*u = NilUUID
+		return nil
+	case string:
+		return u.UnmarshalText([]byte(v))
+	case []byte:
+		if len(v) == len(u) {
+			copy(u[:], v)
+			return nil
+		}
+		return u.UnmarshalText(v)
+	default:
+		return fmt.Errorf("cannot scan %T into UUID", src)
+	}
+}
+
+func (u UUID) Value() (driver.Value, error) {
+	return u.String(), nil
+}
@@ -0,0 +1,92 @@
+package gox_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gopub/gox"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUUID(t *testing.T) {
+	t.Run("V4", func(t *testing.T) {
+		u := gox.NewUUID()
+		require.Equal(t, 4, u.Version())
+		require.NotEqual(t, gox.NewUUID(), u)
+
+		parsed, err := gox.ParseUUID(u.String())
+		require.NoError(t, err)
+		require.Equal(t, u, parsed)
+
+		parsed, err = gox.ParseUUID("urn:uuid:" + strings.ToUpper(u.String()))
+		require.NoError(t, err)
+		require.Equal(t, u, parsed)
+
+		_, err = gox.ParseUUID("not-a-uuid")
+		require.Error(t, err)
+	})
+
+	t.Run("V7", func(t *testing.T) {
+		last := gox.NewUUIDv7()
+		require.Equal(t, 7, last.Version())
+		require.WithinDuration(t, time.Now(), last.Time(), time.Second)
+		for i := 0; i < 10000; i++ {
+			u := gox.NewUUIDv7()
+			require.True(t, u.String() > last.String(), "%s <= %s", u, last)
+			last = u
+		}
+	})
+
+	t.Run("Marshal", func(t *testing.T) {
+		u := gox.NewUUIDv7()
+		b, err := json.Marshal(u)
+		require.NoError(t, err)
+		require.Equal(t, `"`+u.String()+`"`, string(b))
+
+		var u2 gox.UUID
+		require.NoError(t, json.Unmarshal(b, &u2))
+		require.Equal(t, u, u2)
+
+		v, err := u.Value()
+		require.NoError(t, err)
+		var u3 gox.UUID
+		require.NoError(t, u3.Scan(v))
+		require.Equal(t, u, u3)
+	})
+}
+
+func TestULID(t *testing.T) {
+	last := gox.NewULID()
+	require.WithinDuration(t, time.Now(), last.Time(), time.Second)
+	for i := 0; i < 10000; i++ {
+		u := gox.NewULID()
+		require.True(t, u.String() > last.String(), "%s <= %s", u, last)
+		last = u
+	}
+
+	parsed, err := gox.ParseULID(strings.ToLower(last.String()))
+	require.NoError(t, err)
+	require.Equal(t, last, parsed)
+
+	u, err := gox.ParseULID("01ARZ3NDEKTSV4RRFFQ69G5FAV")
+	require.NoError(t, err)
+	require.Equal(t, "01ARZ3NDEKTSV4RRFFQ69G5FAV", u.String())
+	require.Equal(t, int64(1469922850259), u.Time().UnixNano()/int64(time.Millisecond))
+
+	_, err = gox.ParseULID("81ARZ3NDEKTSV4RRFFQ69G5FAV")
+	require.Error(t, err)
+
+	b, err := json.Marshal(u)
+	require.NoError(t, err)
+	var u2 gox.ULID
+	require.NoError(t, json.Unmarshal(b, &u2))
+	require.Equal(t, u, u2)
+
+	v, err := u.Value()
+	require.NoError(t, err)
+	var u3 gox.ULID
+	require.NoError(t, u3.Scan(v))
+	require.Equal(t, u, u3)
+}